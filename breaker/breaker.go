@@ -0,0 +1,208 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the downstream while the breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a circuit breaker with closed, open and half-open states.
+//
+//  1. A closed breaker lets every call through, and trips to open once the
+//     failure rate or the slow call rate in a window reaches the threshold.
+//  2. An open breaker rejects every call with ErrCircuitOpen, and turns
+//     half-open after the open timeout.
+//  3. A half-open breaker lets a few probes through, closes if all of them
+//     succeeded, otherwise opens again.
+type Breaker struct {
+	name   string
+	config *Config
+
+	mu          sync.Mutex
+	state       State
+	windowStart time.Time
+	openedAt    time.Time
+	requests    int64
+	failures    int64
+	slowCalls   int64
+	probing     int64
+	probed      int64
+
+	// state changes to be notified once unlocked.
+	changes []stateChange
+}
+
+type stateChange struct {
+	from, to State
+}
+
+func New(name string, opts ...Option) *Breaker {
+	config := defaultConfig()
+	for _, o := range opts {
+		o(config)
+	}
+
+	return &Breaker{
+		name:        name,
+		config:      config,
+		windowStart: time.Now(),
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.unlock()
+	b.expire(time.Now())
+	return b.state
+}
+
+// Allow check whether a call may go through, every allowed call must report
+// its result with Done.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.unlock()
+
+	b.expire(time.Now())
+	switch b.state {
+	case StateOpen:
+		return ErrCircuitOpen
+	case StateHalfOpen:
+		if b.probing+b.probed >= b.config.halfOpenRequests {
+			return ErrCircuitOpen
+		}
+		b.probing++
+	}
+	return nil
+}
+
+// Done report the result of a call allowed before.
+func (b *Breaker) Done(err error, latency time.Duration) {
+	b.mu.Lock()
+	defer b.unlock()
+
+	now := time.Now()
+	b.expire(now)
+
+	failed := b.config.isFailure(err)
+	slow := b.config.slowCallDuration > 0 && latency >= b.config.slowCallDuration
+
+	switch b.state {
+	case StateClosed:
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if slow {
+			b.slowCalls++
+		}
+		if b.requests >= b.config.minRequests &&
+			(float64(b.failures) >= b.config.errorRate*float64(b.requests) ||
+				b.config.slowCallDuration > 0 && float64(b.slowCalls) >= b.config.slowCallRate*float64(b.requests)) {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		if b.probing > 0 {
+			b.probing--
+		}
+		if failed || slow {
+			b.setState(StateOpen, now)
+			return
+		}
+		b.probed++
+		if b.probed >= b.config.halfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+// Execute call fn if allowed, and report its result.
+func (b *Breaker) Execute(fn func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err := fn()
+	b.Done(err, time.Since(start))
+	return err
+}
+
+// expire move to the next window or state by time, must be locked.
+func (b *Breaker) expire(now time.Time) {
+	switch b.state {
+	case StateClosed:
+		if b.windowStart.Add(b.config.window).Before(now) {
+			b.resetCounters(now)
+		}
+	case StateOpen:
+		if b.openedAt.Add(b.config.openTimeout).Before(now) {
+			b.setState(StateHalfOpen, now)
+		}
+	}
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+
+	from := b.state
+	b.state = state
+	b.resetCounters(now)
+	if state == StateOpen {
+		b.openedAt = now
+	}
+
+	if b.config.onStateChange != nil {
+		b.changes = append(b.changes, stateChange{from: from, to: state})
+	}
+}
+
+// unlock release the lock then notify the state changes, so the callback is
+// free to use the breaker.
+func (b *Breaker) unlock() {
+	changes := b.changes
+	b.changes = nil
+	b.mu.Unlock()
+
+	for _, c := range changes {
+		b.config.onStateChange(b.name, c.from, c.to)
+	}
+}
+
+func (b *Breaker) resetCounters(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+	b.slowCalls = 0
+	b.probing = 0
+	b.probed = 0
+}
@@ -0,0 +1,146 @@
+package breaker
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTest = errors.New("test")
+
+// step of a breaker, one of:
+//
+//	ok, fail, slow  a call allowed then done with the result
+//	probe           a call allowed but not done yet
+//	reject          a call rejected by the breaker
+//	expire          the window and the open timeout passed
+func step(t *testing.T, b *Breaker, do string) {
+	t.Helper()
+	switch do {
+	case "ok", "fail", "slow", "probe":
+		if err := b.Allow(); err != nil {
+			t.Fatalf("%s not allowed in state %s", do, b.State())
+		}
+		switch do {
+		case "ok":
+			b.Done(nil, time.Millisecond)
+		case "fail":
+			b.Done(errTest, time.Millisecond)
+		case "slow":
+			b.Done(nil, time.Second)
+		}
+	case "reject":
+		if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("allowed in state %s, want rejected", b.State())
+		}
+	case "expire":
+		b.mu.Lock()
+		b.windowStart = b.windowStart.Add(-b.config.window - time.Millisecond)
+		b.openedAt = b.openedAt.Add(-b.config.openTimeout - time.Millisecond)
+		b.mu.Unlock()
+	default:
+		t.Fatalf("unknown step %s", do)
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps string
+		want  State
+	}{
+		{"below min requests", "fail fail fail", StateClosed},
+		{"below error rate", "ok ok ok fail", StateClosed},
+		{"tripped by failures", "ok fail ok fail", StateOpen},
+		{"tripped by slow calls", "slow ok slow ok", StateOpen},
+		{"open rejects", "fail fail fail fail reject reject", StateOpen},
+		{"window reset", "fail fail fail expire ok ok ok fail", StateClosed},
+		{"half-open after timeout", "fail fail fail fail expire", StateHalfOpen},
+		{"half-open limits probes", "fail fail fail fail expire probe probe reject", StateHalfOpen},
+		{"half-open closes", "fail fail fail fail expire ok ok", StateClosed},
+		{"half-open waits all probes", "fail fail fail fail expire ok", StateHalfOpen},
+		{"half-open reopens on failure", "fail fail fail fail expire ok fail reject", StateOpen},
+		{"half-open reopens on slow call", "fail fail fail fail expire slow reject", StateOpen},
+		{"closed counts afresh", "fail fail fail fail expire ok ok fail fail fail", StateClosed},
+	}
+	for _, tt := range tests {
+		b := New(tt.name, MinRequests(4), ErrorRate(0.5), SlowCall(100*time.Millisecond, 0.5),
+			Window(time.Minute), OpenTimeout(time.Minute), HalfOpenRequests(2))
+		for _, do := range strings.Fields(tt.steps) {
+			step(t, b, do)
+		}
+		if got := b.State(); got != tt.want {
+			t.Errorf("%s: state = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBreakerIsFailure(t *testing.T) {
+	ignored := errors.New("not found")
+	b := New("test", MinRequests(2), IsFailure(func(err error) bool {
+		return err != nil && !errors.Is(err, ignored)
+	}))
+	for i := 0; i < 4; i++ {
+		if err := b.Execute(func() error { return ignored }); !errors.Is(err, ignored) {
+			t.Fatalf("Execute error = %v", err)
+		}
+	}
+	if got := b.State(); got != StateClosed {
+		t.Errorf("state = %s after ignored errors, want closed", got)
+	}
+
+	step(t, b, "expire")
+	b.Execute(func() error { return errTest })
+	b.Execute(func() error { return errTest })
+	if got := b.State(); got != StateOpen {
+		t.Fatalf("state = %s after failures, want open", got)
+	}
+	called := false
+	if err := b.Execute(func() error { called = true; return nil }); !errors.Is(err, ErrCircuitOpen) || called {
+		t.Errorf("Execute on open = %v, called %t", err, called)
+	}
+}
+
+func TestBreakerOnStateChange(t *testing.T) {
+	var mu sync.Mutex
+	var changes []string
+	var b *Breaker
+	b = New("test", MinRequests(1), OpenTimeout(time.Minute), OnStateChange(func(name string, from, to State) {
+		// the breaker is unlocked in the callback.
+		state := b.State()
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, name+": "+from.String()+" -> "+to.String()+" ("+state.String()+")")
+	}))
+
+	for _, do := range strings.Fields("fail expire ok") {
+		step(t, b, do)
+	}
+	want := []string{
+		"test: closed -> open (open)",
+		"test: open -> half-open (half-open)",
+		"test: half-open -> closed (closed)",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes = %q, want %q", changes, want)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup(MinRequests(1))
+	a := g.Get("a")
+	if g.Get("a") != a {
+		t.Error("Get(a) returns another breaker")
+	}
+	a.Execute(func() error { return errTest })
+	g.Get("b")
+
+	states := g.States()
+	if len(states) != 2 || states["a"] != StateOpen || states["b"] != StateClosed {
+		t.Errorf("States = %v, want a open and b closed", states)
+	}
+}
@@ -0,0 +1,53 @@
+package breaker
+
+import "sync"
+
+// Group hold a breaker per key, eg. per address of a downstream, the breakers
+// are created on demand with the same options.
+type Group struct {
+	opts []Option
+
+	mu       sync.RWMutex
+	breakers map[string]*Breaker
+}
+
+func NewGroup(opts ...Option) *Group {
+	return &Group{
+		opts:     opts,
+		breakers: make(map[string]*Breaker),
+	}
+}
+
+func (g *Group) Get(key string) *Breaker {
+	g.mu.RLock()
+	b, ok := g.breakers[key]
+	g.mu.RUnlock()
+	if ok {
+		return b
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if b, ok := g.breakers[key]; ok {
+		return b
+	}
+	b = New(key, g.opts...)
+	g.breakers[key] = b
+	return b
+}
+
+// States return the current state of every breaker by key.
+func (g *Group) States() map[string]State {
+	g.mu.RLock()
+	breakers := make(map[string]*Breaker, len(g.breakers))
+	for key, b := range g.breakers {
+		breakers[key] = b
+	}
+	g.mu.RUnlock()
+
+	states := make(map[string]State, len(breakers))
+	for key, b := range breakers {
+		states[key] = b.State()
+	}
+	return states
+}
@@ -0,0 +1,98 @@
+package breaker
+
+import "time"
+
+type Config struct {
+	window           time.Duration
+	minRequests      int64
+	errorRate        float64
+	slowCallDuration time.Duration
+	slowCallRate     float64
+	openTimeout      time.Duration
+	halfOpenRequests int64
+	isFailure        func(err error) bool
+	onStateChange    func(name string, from, to State)
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		window:           10 * time.Second,
+		minRequests:      20,
+		errorRate:        0.5,
+		slowCallRate:     1,
+		openTimeout:      5 * time.Second,
+		halfOpenRequests: 1,
+		isFailure: func(err error) bool {
+			return err != nil
+		},
+	}
+}
+
+type Option func(*Config)
+
+// Window the statistic window of the closed breaker, the counters are reset
+// at the end of each window.
+// Default: 10s
+func Window(d time.Duration) Option {
+	return Option(func(c *Config) {
+		c.window = d
+	})
+}
+
+// MinRequests the min requests in a window before the breaker may trip.
+// Default: 20
+func MinRequests(n int64) Option {
+	return Option(func(c *Config) {
+		c.minRequests = n
+	})
+}
+
+// ErrorRate trip the breaker once the failure rate in a window reaches it.
+// Default: 0.5
+func ErrorRate(rate float64) Option {
+	return Option(func(c *Config) {
+		c.errorRate = rate
+	})
+}
+
+// SlowCall trip the breaker once the rate of calls slower than d in a window
+// reaches rate. If d is zero, it doesn't work.
+// Default: disabled
+func SlowCall(d time.Duration, rate float64) Option {
+	return Option(func(c *Config) {
+		c.slowCallDuration = d
+		c.slowCallRate = rate
+	})
+}
+
+// OpenTimeout the time an open breaker waits before letting probes through.
+// Default: 5s
+func OpenTimeout(d time.Duration) Option {
+	return Option(func(c *Config) {
+		c.openTimeout = d
+	})
+}
+
+// HalfOpenRequests the number of probes let through by a half-open breaker,
+// the breaker closes once all of them succeeded.
+// Default: 1
+func HalfOpenRequests(n int64) Option {
+	return Option(func(c *Config) {
+		c.halfOpenRequests = n
+	})
+}
+
+// IsFailure decide which errors are counted as failures.
+// Default: every non-nil error
+func IsFailure(fn func(err error) bool) Option {
+	return Option(func(c *Config) {
+		c.isFailure = fn
+	})
+}
+
+// OnStateChange is called after the breaker changed its state, should not block.
+func OnStateChange(fn func(name string, from, to State)) Option {
+	return Option(func(c *Config) {
+		c.onStateChange = fn
+	})
+}
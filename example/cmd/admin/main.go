@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/YLeseclaireurs/icafe/example/gen-go/proto/admin"
 	"github.com/YLeseclaireurs/icafe/example/service"
 	"github.com/YLeseclaireurs/icafe/server"
@@ -15,5 +17,5 @@ func main() {
 
 	app.AddBundle(grpcBundle)
 
-	app.Run()
+	os.Exit(server.ExitCode(app.Run()))
 }
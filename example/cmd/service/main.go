@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/YLeseclaireurs/icafe/example/gen-go/thrift/content_thrift/content"
 	"github.com/YLeseclaireurs/icafe/example/service"
@@ -18,9 +20,10 @@ func main() {
 	}
 
 	app := server.NewApplication()
-	bundle := rpc.NewTRPCBundle("content-service", rpc.WithTRPCServiceMap(servicesMap), rpc.TRPCListen("0.0.0.0:9000"))
+	bundle := rpc.NewTRPCBundle("content-service", rpc.WithTRPCServiceMap(servicesMap), rpc.TRPCListen("0.0.0.0:9000"),
+		rpc.WithMiddlewares(rpc.RequestID(), rpc.AccessLog(), rpc.Recovery()))
 	app.AddBundle(bundle)
-	app.Run()
+	os.Exit(server.ExitCode(app.Run()))
 
 	/*
 		var transportFactory thrift.TTransportFactory
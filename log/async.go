@@ -0,0 +1,190 @@
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const asyncBufferSize = 64 * 1024
+
+// AsyncWriter queue the writes and write them to the underlying writer in
+// background, the writes are batched by a buffer.
+//
+// Write blocks while the queue is full rather than dropping lines, and writes
+// straight to the underlying writer once closed.
+type AsyncWriter struct {
+	w io.Writer
+
+	queue   chan []byte
+	flushCh chan chan error
+	quit    chan struct{}
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncWriter create an async writer with a queue of size lines.
+func NewAsyncWriter(w io.Writer, size int) *AsyncWriter {
+	a := &AsyncWriter{
+		w:       w,
+		queue:   make(chan []byte, size),
+		flushCh: make(chan chan error),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if a.closed {
+		return a.w.Write(p)
+	}
+	// the buffer of p is reused by the caller.
+	a.queue <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+// Flush block until the queued lines are written to the underlying writer.
+func (a *AsyncWriter) Flush() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return nil
+	}
+	reply := make(chan error, 1)
+	a.flushCh <- reply
+	return <-reply
+}
+
+// Close flush the queued lines, then close the underlying writer if it's an io.Closer.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.quit)
+	a.mu.Unlock()
+
+	<-a.done
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+
+	buf := bufio.NewWriterSize(a.w, asyncBufferSize)
+	write := func(p []byte) {
+		// flush whole lines only, a line is never split by rotation.
+		if buf.Buffered() > 0 && buf.Available() < len(p) {
+			if err := buf.Flush(); err != nil {
+				fmt.Fprintf(os.Stderr, "log: flush error: %v\n", err)
+				buf.Reset(a.w)
+			}
+		}
+		if _, err := buf.Write(p); err != nil {
+			fmt.Fprintf(os.Stderr, "log: write error: %v\n", err)
+			buf.Reset(a.w)
+		}
+	}
+	flush := func() error {
+		for len(a.queue) > 0 {
+			write(<-a.queue)
+		}
+		err := buf.Flush()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "log: flush error: %v\n", err)
+			buf.Reset(a.w)
+		}
+		return err
+	}
+
+	for {
+		select {
+		case p := <-a.queue:
+			write(p)
+			if len(a.queue) == 0 {
+				_ = flush()
+			}
+		case reply := <-a.flushCh:
+			reply <- flush()
+		case <-a.quit:
+			_ = flush()
+			return
+		}
+	}
+}
+
+// output is the file output set by SetOutputFile, closed by Close.
+var (
+	outputMu sync.Mutex
+	output   *AsyncWriter
+)
+
+func init() {
+	// drain the queued lines before Fatal exits.
+	logrus.RegisterExitHandler(func() {
+		_ = Close()
+	})
+}
+
+// SetOutputFile write the standard logger to a rotating file, asynchronously.
+// Close should be called before exiting, so no line is lost.
+func SetOutputFile(filename string, opts ...RotateOption) error {
+	w, err := NewRotateWriter(filename, opts...)
+	if err != nil {
+		return err
+	}
+
+	outputMu.Lock()
+	prev := output
+	output = NewAsyncWriter(w, 1024)
+	SetOutput(output)
+	outputMu.Unlock()
+
+	if prev != nil {
+		return prev.Close()
+	}
+	return nil
+}
+
+// Flush write out the lines queued by the file output.
+func Flush() error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	if output == nil {
+		return nil
+	}
+	return output.Flush()
+}
+
+// Close flush and close the file output, the lines logged afterwards are
+// written to the file synchronously.
+func Close() error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	if output == nil {
+		return nil
+	}
+	return output.Close()
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testWriter record the writes slowly, so the lines are queued.
+type testWriter struct {
+	mu     sync.Mutex
+	buf    strings.Builder
+	writes int
+	closed bool
+}
+
+func (w *testWriter) Write(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes++
+	return w.buf.Write(p)
+}
+
+func (w *testWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+func (w *testWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriterClose(t *testing.T) {
+	w := &testWriter{}
+	a := NewAsyncWriter(w, 1000)
+
+	var want strings.Builder
+	buf := make([]byte, 0, 16)
+	for i := 0; i < 100; i++ {
+		// the buffer is reused by the caller.
+		buf = append(buf[:0], "line "...)
+		buf = append(buf, byte('0'+i%10), '\n')
+		if _, err := a.Write(buf); err != nil {
+			t.Fatal(err)
+		}
+		want.Write(buf)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.String(); got != want.String() {
+		t.Errorf("written after Close = %q, want all the lines", got)
+	}
+	w.mu.Lock()
+	closed, writes := w.closed, w.writes
+	w.mu.Unlock()
+	if !closed {
+		t.Error("the underlying writer is not closed")
+	}
+	if writes >= 100 {
+		t.Errorf("%d writes, want the lines batched", writes)
+	}
+
+	// written straight once closed.
+	if _, err := a.Write([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.String(); !strings.HasSuffix(got, "after\n") {
+		t.Errorf("written after closed = %q", got)
+	}
+	if err := a.Close(); err != nil {
+		t.Errorf("second Close error = %v", err)
+	}
+	if err := a.Flush(); err != nil {
+		t.Errorf("Flush after closed error = %v", err)
+	}
+}
+
+func TestAsyncWriterFlush(t *testing.T) {
+	w := &testWriter{}
+	a := NewAsyncWriter(w, 10)
+	defer a.Close()
+
+	var want strings.Builder
+	for i := 0; i < 20; i++ {
+		line := strings.Repeat("x", i) + "\n"
+		if _, err := a.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		want.WriteString(line)
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.String(); got != want.String() {
+		t.Errorf("written after Flush = %q, want %q", got, want.String())
+	}
+}
+
+func TestAsyncWriterConcurrent(t *testing.T) {
+	w := &testWriter{}
+	a := NewAsyncWriter(w, 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				a.Write([]byte("line\n"))
+			}
+		}()
+	}
+	// Close waits for the writes in flight.
+	wg.Wait()
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(w.String(), "line\n"); got != 400 {
+		t.Errorf("%d lines written, want 400", got)
+	}
+}
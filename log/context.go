@@ -0,0 +1,99 @@
+package log
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	TraceIDField   = "trace_id"
+	SpanIDField    = "span_id"
+	RequestIDField = "request_id"
+	AppField       = "app"
+)
+
+type traceContextKey struct{}
+
+type requestIDContextKey struct{}
+
+var appName atomic.Value
+
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+// ContextWithTrace store the trace and span id in ctx, so the log lines with
+// the context carry them.
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, traceContext{traceID: traceID, spanID: spanID})
+}
+
+// TraceFromContext return the trace and span id stored by ContextWithTrace.
+func TraceFromContext(ctx context.Context) (traceID, spanID string) {
+	if ctx == nil {
+		return "", ""
+	}
+	if tc, ok := ctx.Value(traceContextKey{}).(traceContext); ok {
+		return tc.traceID, tc.spanID
+	}
+	// compatible with the trace id set by telemetry
+	traceID, _ = ctx.Value(traceIDKey).(string)
+	return traceID, ""
+}
+
+// ContextWithRequestID store the request id in ctx.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext return the request id stored by ContextWithRequestID.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// SetAppName set the app name attached to the log lines with context.
+func SetAppName(name string) {
+	appName.Store(name)
+}
+
+// WithContext creates an entry from the standard logger with the context,
+// the trace id, span id, request id in ctx and the app name are added as
+// fields.
+func WithContext(ctx context.Context) *logrus.Entry {
+	return std.WithContext(ctx).WithFields(contextFields(ctx))
+}
+
+func contextFields(ctx context.Context) Fields {
+	fields := Fields{}
+	if traceID, spanID := TraceFromContext(ctx); traceID != "" {
+		fields[TraceIDField] = traceID
+		if spanID != "" {
+			fields[SpanIDField] = spanID
+		}
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		fields[RequestIDField] = requestID
+	}
+	if name, _ := appName.Load().(string); name != "" {
+		fields[AppField] = name
+	}
+	return fields
+}
+
+// splitContext take the leading context out of args, for the callers passing
+// ctx as the first arg of Info, Error, etc.
+func splitContext(args []interface{}) (context.Context, []interface{}) {
+	if len(args) > 0 {
+		if ctx, ok := args[0].(context.Context); ok {
+			return ctx, args[1:]
+		}
+	}
+	return nil, args
+}
@@ -1,9 +1,10 @@
 package log
 
 import (
-	"github.com/sirupsen/logrus"
+	"context"
 	"io"
-	"os"
+
+	"github.com/sirupsen/logrus"
 )
 
 // fork from https://github.com/sirupsen/logrus/blob/v1.6.0/exported.go
@@ -22,26 +23,37 @@ const (
 )
 
 func init() {
+	currentFormatter.Store(formatterHolder{&sampledFormatter{new(formatter)}})
 	std = &logrus.Logger{
-		Out:          os.Stderr,
-		Formatter:    new(formatter),
+		Out:          stdOutput,
+		Formatter:    stdFormatter{},
 		Hooks:        make(logrus.LevelHooks, 0),
 		ReportCaller: false,
 		Level:        logrus.InfoLevel,
 	}
+	std.AddHook(sampleGate{})
 }
 
 func StandardLogger() *logrus.Logger {
 	return std
 }
 
+// SetOutput sets the output of the standard logger and the named loggers.
 func SetOutput(writer io.Writer) {
-	std.SetOutput(writer)
+	stdOutput.set(writer)
 }
 
-// SetLevel sets the standard logger level.
+// AddHook adds a hook to the standard logger hooks, the hook is not fired by
+// the lines dropped by the sampler.
+func AddHook(hook logrus.Hook) {
+	addHook(sampledHook{hook})
+}
+
+// SetLevel sets the standard logger level, and the named loggers without
+// their own level.
 func SetLevel(level Level) {
 	std.SetLevel(level)
+	followStdLevel(level)
 }
 
 // GetLevel returns the standard logger level.
@@ -64,28 +76,49 @@ func WithFields(fields Fields) *logrus.Entry {
 	return std.WithFields(fields)
 }
 
-// Debug logs a message at level Debug on the standard logger.
+// Debug logs a message at level Debug on the standard logger. A leading
+// context.Context in args is taken as the context of the entry, see WithContext.
 func Debug(args ...interface{}) {
+	if ctx, rest := splitContext(args); ctx != nil {
+		WithContext(ctx).Debug(rest...)
+		return
+	}
 	std.Debug(args...)
 }
 
 // Info logs a message at level Info on the standard logger.
 func Info(args ...interface{}) {
+	if ctx, rest := splitContext(args); ctx != nil {
+		WithContext(ctx).Info(rest...)
+		return
+	}
 	std.Info(args...)
 }
 
 // Warn logs a message at level Warn on the standard logger.
 func Warn(args ...interface{}) {
+	if ctx, rest := splitContext(args); ctx != nil {
+		WithContext(ctx).Warn(rest...)
+		return
+	}
 	std.Warn(args...)
 }
 
 // Error logs a message at level Error on the standard logger.
 func Error(args ...interface{}) {
+	if ctx, rest := splitContext(args); ctx != nil {
+		WithContext(ctx).Error(rest...)
+		return
+	}
 	std.Error(args...)
 }
 
 // Fatal logs a message at level Fatal on the standard logger then the process will exit with status set to 1.
 func Fatal(args ...interface{}) {
+	if ctx, rest := splitContext(args); ctx != nil {
+		WithContext(ctx).Fatal(rest...)
+		return
+	}
 	std.Fatal(args...)
 }
 
@@ -113,3 +146,43 @@ func Errorf(format string, args ...interface{}) {
 func Fatalf(format string, args ...interface{}) {
 	std.Fatalf(format, args...)
 }
+
+// DebugContext logs a message at level Debug with the context, see WithContext.
+func DebugContext(ctx context.Context, args ...interface{}) {
+	WithContext(ctx).Debug(args...)
+}
+
+// InfoContext logs a message at level Info with the context, see WithContext.
+func InfoContext(ctx context.Context, args ...interface{}) {
+	WithContext(ctx).Info(args...)
+}
+
+// WarnContext logs a message at level Warn with the context, see WithContext.
+func WarnContext(ctx context.Context, args ...interface{}) {
+	WithContext(ctx).Warn(args...)
+}
+
+// ErrorContext logs a message at level Error with the context, see WithContext.
+func ErrorContext(ctx context.Context, args ...interface{}) {
+	WithContext(ctx).Error(args...)
+}
+
+// DebugContextf logs a message at level Debug with the context, see WithContext.
+func DebugContextf(ctx context.Context, format string, args ...interface{}) {
+	WithContext(ctx).Debugf(format, args...)
+}
+
+// InfoContextf logs a message at level Info with the context, see WithContext.
+func InfoContextf(ctx context.Context, format string, args ...interface{}) {
+	WithContext(ctx).Infof(format, args...)
+}
+
+// WarnContextf logs a message at level Warn with the context, see WithContext.
+func WarnContextf(ctx context.Context, format string, args ...interface{}) {
+	WithContext(ctx).Warnf(format, args...)
+}
+
+// ErrorContextf logs a message at level Error with the context, see WithContext.
+func ErrorContextf(ctx context.Context, format string, args ...interface{}) {
+	WithContext(ctx).Errorf(format, args...)
+}
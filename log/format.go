@@ -0,0 +1,152 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+const (
+	timeField   = "time"
+	levelField  = "level"
+	msgField    = "msg"
+	callerField = "caller"
+	hostField   = "host"
+	pidField    = "pid"
+)
+
+// SetFormatter sets the standard logger formatter, the named loggers follow.
+func SetFormatter(f logrus.Formatter) {
+	currentFormatter.Store(formatterHolder{&sampledFormatter{f}})
+}
+
+// SetFormat select the formatter by name, one of text, json and logfmt.
+func SetFormat(format string) error {
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		SetFormatter(NewTextFormatter())
+	case FormatJSON:
+		SetFormatter(NewJSONFormatter())
+	case FormatLogfmt:
+		SetFormatter(NewLogfmtFormatter())
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+	return nil
+}
+
+type jsonFormatter struct{}
+
+// NewJSONFormatter return a formatter writing a JSON object per line.
+func NewJSONFormatter() logrus.Formatter {
+	return new(jsonFormatter)
+}
+
+func (f *jsonFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(entry.Data)+6)
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		data[k] = v
+	}
+	if traceID, spanID := TraceFromContext(entry.Context); traceID != "" {
+		data[TraceIDField] = traceID
+		if spanID != "" {
+			data[SpanIDField] = spanID
+		}
+	}
+	data[timeField] = entry.Time.Format(time.RFC3339Nano)
+	data[levelField] = entry.Level.String()
+	data[msgField] = entry.Message
+	data[hostField] = host
+	data[pidField] = processID
+	if caller := callerOf(entry); caller != nil {
+		data[callerField] = caller.File + ":" + strconv.Itoa(caller.Line)
+	}
+
+	encoder := json.NewEncoder(entry.Buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return nil, fmt.Errorf("failed to marshal log entry to JSON: %w", err)
+	}
+	return entry.Buffer.Bytes(), nil
+}
+
+type logfmtFormatter struct{}
+
+// NewLogfmtFormatter return a formatter writing key=value pairs per line.
+func NewLogfmtFormatter() logrus.Formatter {
+	return new(logfmtFormatter)
+}
+
+func (f *logfmtFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	writePair := func(k string, v interface{}) {
+		if entry.Buffer.Len() > 0 {
+			entry.Buffer.WriteByte(' ')
+		}
+		entry.Buffer.WriteString(k)
+		entry.Buffer.WriteByte('=')
+		entry.Buffer.WriteString(logfmtValue(v))
+	}
+
+	writePair(timeField, entry.Time.Format(time.RFC3339Nano))
+	writePair(levelField, entry.Level.String())
+	if caller := callerOf(entry); caller != nil {
+		writePair(callerField, caller.File+":"+strconv.Itoa(caller.Line))
+	}
+	writePair(hostField, host)
+	writePair(pidField, processID)
+
+	data := entry.Data
+	if traceID, spanID := TraceFromContext(entry.Context); traceID != "" {
+		if _, ok := data[TraceIDField]; !ok {
+			writePair(TraceIDField, traceID)
+			if spanID != "" {
+				writePair(SpanIDField, spanID)
+			}
+		}
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writePair(k, data[k])
+	}
+
+	writePair(msgField, entry.Message)
+	entry.Buffer.WriteByte('\n')
+	return entry.Buffer.Bytes(), nil
+}
+
+func logfmtValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 )
@@ -16,6 +20,15 @@ const (
 
 var (
 	processID = strconv.Itoa(os.Getpid())
+	host      = hostName()
+)
+
+var (
+	// reportCaller enable the caller column, see SetReportCaller.
+	reportCaller atomic.Bool
+
+	logPackage    = reflect.TypeOf(formatter{}).PkgPath()
+	logrusPackage = reflect.TypeOf(logrus.Logger{}).PkgPath()
 )
 
 var (
@@ -30,8 +43,16 @@ var (
 	}
 )
 
+// formatter is the text formatter, eg.
+//
+//	[I 2006-01-02 15:04:05.000 traceid file.go:10 host:pid] [{"k":"v"}] message
 type formatter struct{}
 
+// NewTextFormatter return the bracketed text formatter, it's the default.
+func NewTextFormatter() logrus.Formatter {
+	return new(formatter)
+}
+
 func (f *formatter) Format(entry *logrus.Entry) ([]byte, error) {
 	entry.Buffer.WriteByte('[')
 	entry.Buffer.WriteByte(severityMap[entry.Level])
@@ -39,31 +60,29 @@ func (f *formatter) Format(entry *logrus.Entry) ([]byte, error) {
 	entry.Buffer.WriteString(entry.Time.Format("2006-01-02 15:04:05.000"))
 	entry.Buffer.WriteByte(' ')
 
-	var traceID string
-	if entry.Context != nil {
-		traceID, _ = entry.Context.Value(traceIDKey).(string)
-	}
+	traceID, _ := TraceFromContext(entry.Context)
 	if traceID != "" {
 		entry.Buffer.WriteString(traceID)
 	} else {
 		entry.Buffer.WriteByte('-')
 	}
 	entry.Buffer.WriteByte(' ')
-	if entry.Caller != nil {
-		entry.Buffer.WriteString(filepath.Base(entry.Caller.File))
+	if caller := callerOf(entry); caller != nil {
+		entry.Buffer.WriteString(filepath.Base(caller.File))
 		entry.Buffer.WriteByte(':')
-		entry.Buffer.WriteString(strconv.Itoa(entry.Caller.Line))
+		entry.Buffer.WriteString(strconv.Itoa(caller.Line))
 	} else {
 		entry.Buffer.WriteByte('-')
 	}
 
 	entry.Buffer.WriteByte(' ')
-	entry.Buffer.WriteString(hostName())
+	entry.Buffer.WriteString(host)
 	entry.Buffer.WriteByte(':')
 	entry.Buffer.WriteString(processID)
 	entry.Buffer.WriteString("] ")
-	if len(entry.Data) > 0 {
-		if extraData, err := json.Marshal(entry.Data); err == nil {
+	data := textFields(entry.Data)
+	if len(data) > 0 {
+		if extraData, err := json.Marshal(data); err == nil {
 			entry.Buffer.WriteString("[")
 			entry.Buffer.Write(extraData)
 			entry.Buffer.WriteString("] ")
@@ -75,6 +94,62 @@ func (f *formatter) Format(entry *logrus.Entry) ([]byte, error) {
 	return entry.Buffer.Bytes(), nil
 }
 
+// textFields drop the fields implied by the bracketed columns, the trace id
+// is printed as a column and the app name is implied by host and pid.
+func textFields(data logrus.Fields) logrus.Fields {
+	_, hasTraceID := data[TraceIDField]
+	_, hasApp := data[AppField]
+	if !hasTraceID && !hasApp {
+		return data
+	}
+
+	fields := make(logrus.Fields, len(data))
+	for k, v := range data {
+		if k != TraceIDField && k != AppField {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// SetReportCaller enable or disable reporting the file and line of the caller.
+func SetReportCaller(enable bool) {
+	reportCaller.Store(enable)
+}
+
+// callerOf return the frame calling the log package if caller reporting is
+// enabled, the frames of this package and logrus are skipped.
+func callerOf(entry *logrus.Entry) *runtime.Frame {
+	if entry.Caller != nil && !isLogFrame(entry.Caller.Function) {
+		return entry.Caller
+	}
+	if !reportCaller.Load() {
+		return nil
+	}
+	return callerFrame()
+}
+
+// callerFrame return the first frame of the stack out of the logger packages.
+func callerFrame() *runtime.Frame {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		// the tests of the package log as callers.
+		if !isLogFrame(frame.Function) || strings.HasSuffix(frame.File, "_test.go") {
+			return &frame
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+func isLogFrame(function string) bool {
+	return strings.HasPrefix(function, logPackage+".") || strings.HasPrefix(function, logrusPackage+".")
+}
+
 func hostName() string {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -0,0 +1,236 @@
+package log
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LoggerField is the field carrying the name of a named logger.
+const LoggerField = "logger"
+
+// Logger is a named logger with its own level, it shares the output,
+// formatter and hooks of the standard logger.
+type Logger struct {
+	name   string
+	logger *logrus.Logger
+
+	// explicit is whether the level is set for this logger, otherwise it
+	// follows the standard logger.
+	explicit bool
+}
+
+var (
+	loggersMu sync.Mutex
+	loggers   = map[string]*Logger{}
+	levels    = map[string]Level{}
+)
+
+// sharedOutput is the output of the standard logger and the named loggers,
+// the lines of all the loggers are written under its lock, so they don't
+// interleave or race with SetOutput.
+type sharedOutput struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var stdOutput = &sharedOutput{w: os.Stderr}
+
+func (o *sharedOutput) Write(p []byte) (int, error) {
+	// the lines dropped by the sampler are formatted as nothing.
+	if len(p) == 0 {
+		return 0, nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.w.Write(p)
+}
+
+func (o *sharedOutput) set(w io.Writer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.w = w
+}
+
+// formatterHolder keep the stored type the same for atomic.Value.
+type formatterHolder struct {
+	logrus.Formatter
+}
+
+// currentFormatter hold the formatter set by SetFormatter.
+var currentFormatter atomic.Value
+
+// stdFormatter format by the formatter set by SetFormatter, it's the formatter
+// of the standard logger and the named loggers.
+type stdFormatter struct{}
+
+func (stdFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	return currentFormatter.Load().(formatterHolder).Format(entry)
+}
+
+// Named return the logger of name, eg. the package name, it's created on the
+// first call.
+func Named(name string) *Logger {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+
+	if l, ok := loggers[name]; ok {
+		return l
+	}
+
+	level, explicit := levels[name]
+	if !explicit {
+		level = std.GetLevel()
+	}
+	// the hooks are copied, AddHook adds the later ones to every logger.
+	hooks := make(logrus.LevelHooks, len(std.Hooks))
+	for level, levelHooks := range std.Hooks {
+		hooks[level] = append([]logrus.Hook(nil), levelHooks...)
+	}
+	l := &Logger{
+		name: name,
+		logger: &logrus.Logger{
+			Out:       stdOutput,
+			Formatter: stdFormatter{},
+			Hooks:     hooks,
+			Level:     level,
+		},
+		explicit: explicit,
+	}
+	loggers[name] = l
+	return l
+}
+
+// SetLevelOf set the level of the named logger, including the one created later.
+func SetLevelOf(name string, level Level) {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+
+	levels[name] = level
+	if l, ok := loggers[name]; ok {
+		l.explicit = true
+		l.logger.SetLevel(level)
+	}
+}
+
+// addHook add the hook to the standard logger and the named loggers.
+func addHook(hook logrus.Hook) {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+
+	std.AddHook(hook)
+	for _, l := range loggers {
+		l.logger.AddHook(hook)
+	}
+}
+
+// followStdLevel update the level of the named loggers without an explicit level.
+func followStdLevel(level Level) {
+	loggersMu.Lock()
+	defer loggersMu.Unlock()
+
+	for _, l := range loggers {
+		if !l.explicit {
+			l.logger.SetLevel(level)
+		}
+	}
+}
+
+func (l *Logger) Name() string {
+	return l.name
+}
+
+func (l *Logger) SetLevel(level Level) {
+	SetLevelOf(l.name, level)
+}
+
+func (l *Logger) GetLevel() Level {
+	return l.logger.GetLevel()
+}
+
+func (l *Logger) IsLevelEnabled(level Level) bool {
+	return l.logger.IsLevelEnabled(level)
+}
+
+func (l *Logger) entry() *logrus.Entry {
+	return l.logger.WithField(LoggerField, l.name)
+}
+
+// WithFields creates an entry from the logger and adds multiple fields to it.
+func (l *Logger) WithFields(fields Fields) *logrus.Entry {
+	return l.entry().WithFields(fields)
+}
+
+// WithContext creates an entry from the logger with the context, see the
+// package level WithContext.
+func (l *Logger) WithContext(ctx context.Context) *logrus.Entry {
+	return l.entry().WithContext(ctx).WithFields(contextFields(ctx))
+}
+
+func (l *Logger) Debug(args ...interface{}) {
+	l.entry().Debug(args...)
+}
+
+func (l *Logger) Info(args ...interface{}) {
+	l.entry().Info(args...)
+}
+
+func (l *Logger) Warn(args ...interface{}) {
+	l.entry().Warn(args...)
+}
+
+func (l *Logger) Error(args ...interface{}) {
+	l.entry().Error(args...)
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.entry().Debugf(format, args...)
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.entry().Infof(format, args...)
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.entry().Warnf(format, args...)
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.entry().Errorf(format, args...)
+}
+
+func (l *Logger) DebugContext(ctx context.Context, args ...interface{}) {
+	l.WithContext(ctx).Debug(args...)
+}
+
+func (l *Logger) InfoContext(ctx context.Context, args ...interface{}) {
+	l.WithContext(ctx).Info(args...)
+}
+
+func (l *Logger) WarnContext(ctx context.Context, args ...interface{}) {
+	l.WithContext(ctx).Warn(args...)
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, args ...interface{}) {
+	l.WithContext(ctx).Error(args...)
+}
+
+func (l *Logger) DebugContextf(ctx context.Context, format string, args ...interface{}) {
+	l.WithContext(ctx).Debugf(format, args...)
+}
+
+func (l *Logger) InfoContextf(ctx context.Context, format string, args ...interface{}) {
+	l.WithContext(ctx).Infof(format, args...)
+}
+
+func (l *Logger) WarnContextf(ctx context.Context, format string, args ...interface{}) {
+	l.WithContext(ctx).Warnf(format, args...)
+}
+
+func (l *Logger) ErrorContextf(ctx context.Context, format string, args ...interface{}) {
+	l.WithContext(ctx).Errorf(format, args...)
+}
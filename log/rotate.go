@@ -0,0 +1,390 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	compressSuffix   = ".gz"
+	dayFormat        = "2006-01-02"
+)
+
+type rotateConfig struct {
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	daily      bool
+	compress   bool
+}
+
+func defaultRotateConfig() *rotateConfig {
+	return &rotateConfig{
+		maxSize:  100 * 1024 * 1024,
+		daily:    true,
+		compress: true,
+	}
+}
+
+type RotateOption func(*rotateConfig)
+
+// RotateMaxSize set the max size in bytes of the file before rotated, 0 means no limit.
+// Default: 100MB
+func RotateMaxSize(size int64) RotateOption {
+	return func(c *rotateConfig) {
+		c.maxSize = size
+	}
+}
+
+// RotateMaxBackups set the max number of rotated files to keep, 0 means keep all.
+// Default: 0
+func RotateMaxBackups(n int) RotateOption {
+	return func(c *rotateConfig) {
+		c.maxBackups = n
+	}
+}
+
+// RotateMaxAge set how long the rotated files are kept, 0 means forever.
+// Default: 0
+func RotateMaxAge(age time.Duration) RotateOption {
+	return func(c *rotateConfig) {
+		c.maxAge = age
+	}
+}
+
+// RotateDaily rotate the file once the day changed.
+// Default: true
+func RotateDaily(enable bool) RotateOption {
+	return func(c *rotateConfig) {
+		c.daily = enable
+	}
+}
+
+// RotateCompress gzip the rotated files.
+// Default: true
+func RotateCompress(enable bool) RotateOption {
+	return func(c *rotateConfig) {
+		c.compress = enable
+	}
+}
+
+// RotateWriter write to a file and rotate it by size and by day.
+//
+// The rotated file is renamed to name-<time>.ext by the time of its last line,
+// eg. app-2006-01-02T15-04-05.000.log, with a "-<n>" suffix if the name is
+// taken, then compressed and cleaned up by retention in background.
+type RotateWriter struct {
+	filename string
+	config   *rotateConfig
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	day  string
+	// lastWrite is the time of the last line, it names the rotated file so a
+	// daily one is named by its day rather than the next.
+	lastWrite time.Time
+
+	millMu sync.Mutex
+}
+
+func NewRotateWriter(filename string, opts ...RotateOption) (*RotateWriter, error) {
+	config := defaultRotateConfig()
+	for _, o := range opts {
+		o(config)
+	}
+
+	w := &RotateWriter{
+		filename: filename,
+		config:   config,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write is safe for concurrent use, the file is reopened if closed.
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	if w.size > 0 && (w.config.daily && now.Format(dayFormat) != w.day ||
+		w.config.maxSize > 0 && w.size+int64(len(p)) > w.config.maxSize) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	w.lastWrite = now
+	return n, err
+}
+
+// Rotate close the current file and start a new one.
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	return w.rotate()
+}
+
+// Sync commit the written content to disk.
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// open the file for appending, the day of an existing file is its
+// modification time, so it's rotated on the first write of another day.
+func (w *RotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.lastWrite = info.ModTime()
+	if w.size == 0 {
+		w.lastWrite = time.Now()
+	}
+	w.day = w.lastWrite.Format(dayFormat)
+	return nil
+}
+
+func (w *RotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	if err := os.Rename(w.filename, w.backupName(w.lastWrite)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	go w.mill()
+	return nil
+}
+
+// backupName return the name of the file rotated at t, suffixed by "-<n>" if
+// a file of t exists, eg. rotated twice in a millisecond.
+func (w *RotateWriter) backupName(t time.Time) string {
+	prefix, ext := w.backupPattern()
+	base := prefix + t.Format(backupTimeFormat)
+	name := base + ext
+	for n := 1; backupExists(name); n++ {
+		name = base + "-" + strconv.Itoa(n) + ext
+	}
+	return name
+}
+
+func backupExists(name string) bool {
+	for _, path := range []string{name, name + compressSuffix} {
+		if _, err := os.Lstat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// backupPattern return the prefix and extension of the rotated files.
+func (w *RotateWriter) backupPattern() (prefix, ext string) {
+	ext = filepath.Ext(w.filename)
+	return strings.TrimSuffix(w.filename, ext) + "-", ext
+}
+
+type backupFile struct {
+	path string
+	time time.Time
+	// seq is the "-<n>" suffix of the files rotated at the same time.
+	seq int
+}
+
+// mill compress the rotated files and remove the ones out of retention.
+func (w *RotateWriter) mill() {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	backups, err := w.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log: list rotated files error: %v\n", err)
+		return
+	}
+
+	var remove []backupFile
+	if w.config.maxBackups > 0 && len(backups) > w.config.maxBackups {
+		remove = append(remove, backups[w.config.maxBackups:]...)
+		backups = backups[:w.config.maxBackups]
+	}
+	if w.config.maxAge > 0 {
+		cutoff := time.Now().Add(-w.config.maxAge)
+		kept := backups[:0]
+		for _, b := range backups {
+			if b.time.Before(cutoff) {
+				remove = append(remove, b)
+			} else {
+				kept = append(kept, b)
+			}
+		}
+		backups = kept
+	}
+
+	for _, b := range remove {
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "log: remove rotated file error: %v\n", err)
+		}
+	}
+	if w.config.compress {
+		for _, b := range backups {
+			if strings.HasSuffix(b.path, compressSuffix) {
+				continue
+			}
+			if err := compressFile(b.path); err != nil {
+				fmt.Fprintf(os.Stderr, "log: compress rotated file error: %v\n", err)
+			}
+		}
+	}
+}
+
+// backups return the rotated files, the newest first.
+func (w *RotateWriter) backups() ([]backupFile, error) {
+	entries, err := os.ReadDir(filepath.Dir(w.filename))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, ext := w.backupPattern()
+	prefix = filepath.Base(prefix)
+
+	var backups []backupFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), compressSuffix)
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, seq, ok := parseBackupTime(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if !ok {
+			continue
+		}
+		backups = append(backups, backupFile{
+			path: filepath.Join(filepath.Dir(w.filename), e.Name()),
+			time: t,
+			seq:  seq,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.After(backups[j].time)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	return backups, nil
+}
+
+// parseBackupTime parse "<time>" or "<time>-<n>" of a rotated file.
+func parseBackupTime(s string) (t time.Time, seq int, ok bool) {
+	if len(s) < len(backupTimeFormat) {
+		return time.Time{}, 0, false
+	}
+	t, err := time.ParseInLocation(backupTimeFormat, s[:len(backupTimeFormat)], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	if rest := s[len(backupTimeFormat):]; rest != "" {
+		if !strings.HasPrefix(rest, "-") {
+			return time.Time{}, 0, false
+		}
+		if seq, err = strconv.Atoi(rest[1:]); err != nil || seq <= 0 {
+			return time.Time{}, 0, false
+		}
+	}
+	return t, seq, true
+}
+
+// compressFile gzip the file into file.gz and remove it.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + compressSuffix + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+compressSuffix); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
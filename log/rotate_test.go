@@ -0,0 +1,289 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listDir return the names in dir, sorted.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, compressSuffix) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// millNow mill the rotated files, the mills started by the rotations find
+// nothing left to do.
+func millNow(w *RotateWriter) {
+	w.mill()
+}
+
+func newTestRotateWriter(t *testing.T, opts ...RotateOption) (*RotateWriter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	w, err := NewRotateWriter(filepath.Join(dir, "app.log"),
+		append([]RotateOption{RotateCompress(false), RotateDaily(false)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		w.Close()
+		millNow(w)
+	})
+	return w, dir
+}
+
+func writeLines(t *testing.T, w io.Writer, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRotateWriterSize(t *testing.T) {
+	w, dir := newTestRotateWriter(t, RotateMaxSize(10))
+
+	// a line is never split, the file rotates before the line exceeding the size.
+	writeLines(t, w, "aaaa", "bbbb", "cccc", "dd", "eeeeee")
+	millNow(w)
+
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, b := range backups {
+		contents = append(contents, readFile(t, b.path))
+	}
+	if got := strings.Join(contents, "|"); got != "cccc\ndd\n|aaaa\nbbbb\n" {
+		t.Errorf("backups = %q, want the newest first", contents)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != "eeeeee\n" {
+		t.Errorf("current = %q", got)
+	}
+}
+
+func TestRotateWriterDaily(t *testing.T) {
+	w, dir := newTestRotateWriter(t, RotateDaily(true))
+	writeLines(t, w, "yesterday")
+
+	// as if the line was written yesterday.
+	yesterday := time.Now().AddDate(0, 0, -1)
+	w.mu.Lock()
+	w.day = yesterday.Format(dayFormat)
+	w.lastWrite = yesterday
+	w.mu.Unlock()
+
+	writeLines(t, w, "today")
+	millNow(w)
+
+	want := "app-" + yesterday.Format(backupTimeFormat) + ".log"
+	if names := listDir(t, dir); len(names) != 2 || names[0] != want {
+		t.Fatalf("files = %v, want %s named by its day", names, want)
+	}
+	if got := readFile(t, filepath.Join(dir, want)); got != "yesterday\n" {
+		t.Errorf("backup = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != "today\n" {
+		t.Errorf("current = %q", got)
+	}
+}
+
+func TestRotateWriterSameMillisecond(t *testing.T) {
+	w, dir := newTestRotateWriter(t)
+	lastWrite := time.Now()
+	for _, line := range []string{"first", "second", "third"} {
+		writeLines(t, w, line)
+		w.mu.Lock()
+		w.lastWrite = lastWrite
+		w.mu.Unlock()
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	millNow(w)
+
+	base := "app-" + lastWrite.Format(backupTimeFormat)
+	want := map[string]string{
+		base + ".log":   "first\n",
+		base + "-1.log": "second\n",
+		base + "-2.log": "third\n",
+	}
+	for name, content := range want {
+		if got := readFile(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+
+	// the later ones of the same time are newer.
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, b := range backups {
+		order = append(order, filepath.Base(b.path))
+	}
+	if got := strings.Join(order, ","); got != base+"-2.log,"+base+"-1.log,"+base+".log" {
+		t.Errorf("backups = %s, want the newest first", got)
+	}
+}
+
+func TestRotateWriterRetention(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		opts []RotateOption
+		// the ages of the backups existing, and of the ones kept after a rotation.
+		ages []time.Duration
+		kept []time.Duration
+	}{
+		{"keep all", nil,
+			[]time.Duration{time.Hour, 48 * time.Hour}, []time.Duration{0, time.Hour, 48 * time.Hour}},
+		{"max backups", []RotateOption{RotateMaxBackups(2)},
+			[]time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour}, []time.Duration{0, time.Hour}},
+		{"max age", []RotateOption{RotateMaxAge(24 * time.Hour)},
+			[]time.Duration{time.Hour, 48 * time.Hour, 72 * time.Hour}, []time.Duration{0, time.Hour}},
+		{"both", []RotateOption{RotateMaxBackups(3), RotateMaxAge(24 * time.Hour)},
+			[]time.Duration{time.Hour, 2 * time.Hour, 48 * time.Hour}, []time.Duration{0, time.Hour, 2 * time.Hour}},
+	}
+	for _, tt := range tests {
+		w, dir := newTestRotateWriter(t, tt.opts...)
+		for _, age := range tt.ages {
+			name := "app-" + now.Add(-age).Format(backupTimeFormat) + ".log"
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// other files are never removed.
+		if err := os.WriteFile(filepath.Join(dir, "app-other.log"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		writeLines(t, w, "current")
+		w.mu.Lock()
+		w.lastWrite = now
+		w.mu.Unlock()
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		millNow(w)
+
+		want := []string{"app-other.log", "app.log"}
+		for _, age := range tt.kept {
+			want = append(want, "app-"+now.Add(-age).Format(backupTimeFormat)+".log")
+		}
+		sort.Strings(want)
+		if got := listDir(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: files = %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestRotateWriterCompress(t *testing.T) {
+	w, dir := newTestRotateWriter(t, RotateCompress(true))
+	writeLines(t, w, "compressed")
+	lastWrite := time.Now()
+	w.mu.Lock()
+	w.lastWrite = lastWrite
+	w.mu.Unlock()
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	millNow(w)
+
+	name := "app-" + lastWrite.Format(backupTimeFormat) + ".log" + compressSuffix
+	if names := listDir(t, dir); strings.Join(names, ",") != name+",app.log" {
+		t.Fatalf("files = %v, want %s and app.log", names, name)
+	}
+	if got := readFile(t, filepath.Join(dir, name)); got != "compressed\n" {
+		t.Errorf("backup = %q", got)
+	}
+
+	// a compressed backup takes the name as well.
+	if got := w.backupName(lastWrite); filepath.Base(got) != "app-"+lastWrite.Format(backupTimeFormat)+"-1.log" {
+		t.Errorf("backupName = %s, want the -1 suffix", got)
+	}
+}
+
+func TestRotateWriterReopen(t *testing.T) {
+	w, dir := newTestRotateWriter(t, RotateMaxSize(10))
+	writeLines(t, w, "aaaa")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the size of the existing file counts after reopened.
+	writeLines(t, w, "bbbb", "cccc")
+	millNow(w)
+	if names := listDir(t, dir); len(names) != 2 {
+		t.Errorf("files = %v, want a backup and the current", names)
+	}
+	if got := readFile(t, filepath.Join(dir, "app.log")); got != "cccc\n" {
+		t.Errorf("current = %q", got)
+	}
+}
+
+func TestParseBackupTime(t *testing.T) {
+	tests := []struct {
+		s   string
+		seq int
+		ok  bool
+	}{
+		{"2023-01-02T15-04-05.000", 0, true},
+		{"2023-01-02T15-04-05.000-2", 2, true},
+		{"2023-01-02T15-04-05.000-0", 0, false},
+		{"2023-01-02T15-04-05.000-x", 0, false},
+		{"2023-01-02T15-04-05.000x", 0, false},
+		{"2023-01-02", 0, false},
+		{"other", 0, false},
+	}
+	for _, tt := range tests {
+		tm, seq, ok := parseBackupTime(tt.s)
+		if ok != tt.ok || seq != tt.seq {
+			t.Errorf("parseBackupTime(%s) = %d, %t, want %d, %t", tt.s, seq, ok, tt.seq, tt.ok)
+		}
+		if ok && tm.Format(backupTimeFormat) != tt.s[:len(backupTimeFormat)] {
+			t.Errorf("parseBackupTime(%s) time = %s", tt.s, tm)
+		}
+	}
+}
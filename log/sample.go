@@ -0,0 +1,254 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SuppressedField is the field carrying the number of suppressed lines in a summary.
+const SuppressedField = "suppressed"
+
+// SampleKeyField is the field to sample the lines by instead of their call
+// site, eg. log.WithFields(log.Fields{log.SampleKeyField: "redis retry"}).Error(err).
+const SampleKeyField = "sample_key"
+
+type samplerConfig struct {
+	window          time.Duration
+	summaryInterval time.Duration
+	levels          []Level
+}
+
+type SamplerOption func(*samplerConfig)
+
+// SampleWindow set the window the first lines of each call site are counted in.
+// Default: 1s
+func SampleWindow(d time.Duration) SamplerOption {
+	return func(c *samplerConfig) {
+		c.window = d
+	}
+}
+
+// SampleSummaryInterval set the interval to log the number of suppressed lines.
+// Default: 10s
+func SampleSummaryInterval(d time.Duration) SamplerOption {
+	return func(c *samplerConfig) {
+		c.summaryInterval = d
+	}
+}
+
+// SampleLevels set the levels sampled, the other levels are always logged.
+// Default: debug, info, warn and error
+func SampleLevels(levels ...Level) SamplerOption {
+	return func(c *samplerConfig) {
+		c.levels = levels
+	}
+}
+
+type sampleKey struct {
+	level Level
+	site  string
+}
+
+type suppression struct {
+	count int64
+	// message is the last suppressed message, logged in the summary.
+	message string
+}
+
+// Sampler limit the lines of the same level and call site, whatever the
+// values formatted in the message: in every window the first lines are
+// logged, after which only one in every thereafter lines is logged. The
+// number of suppressed lines is logged periodically.
+type Sampler struct {
+	first      int64
+	thereafter int64
+	config     *samplerConfig
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[sampleKey]int64
+	suppressed  map[sampleKey]*suppression
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewSampler log the first lines of each call site in a window, then one in
+// every thereafter lines, 0 thereafter suppress all the rest.
+func NewSampler(first, thereafter int, opts ...SamplerOption) *Sampler {
+	config := &samplerConfig{
+		window:          time.Second,
+		summaryInterval: 10 * time.Second,
+		levels:          []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel},
+	}
+	for _, o := range opts {
+		o(config)
+	}
+
+	s := &Sampler{
+		first:       int64(first),
+		thereafter:  int64(thereafter),
+		config:      config,
+		windowStart: time.Now(),
+		counts:      map[sampleKey]int64{},
+		suppressed:  map[sampleKey]*suppression{},
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go s.summarize()
+	return s
+}
+
+// Sample report whether the entry should be logged.
+func (s *Sampler) Sample(entry *logrus.Entry) bool {
+	if _, ok := entry.Data[SuppressedField]; ok || !s.sampled(entry.Level) {
+		return true
+	}
+
+	key := sampleKey{level: entry.Level, site: sampleSite(entry)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := time.Now(); now.Sub(s.windowStart) >= s.config.window {
+		s.windowStart = now
+		s.counts = map[sampleKey]int64{}
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.first || s.thereafter > 0 && (n-s.first)%s.thereafter == 0 {
+		return true
+	}
+	sup, ok := s.suppressed[key]
+	if !ok {
+		sup = &suppression{}
+		s.suppressed[key] = sup
+	}
+	sup.count++
+	sup.message = entry.Message
+	return false
+}
+
+// sampleSite return the SampleKeyField of the entry, or the file and line
+// logging it.
+func sampleSite(entry *logrus.Entry) string {
+	if key, ok := entry.Data[SampleKeyField]; ok {
+		return fmt.Sprint(key)
+	}
+	if caller := callerFrame(); caller != nil {
+		return caller.File + ":" + strconv.Itoa(caller.Line)
+	}
+	return ""
+}
+
+// Stop the periodic summary, the suppressed lines not summarized yet are
+// summarized right now.
+func (s *Sampler) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func (s *Sampler) sampled(level Level) bool {
+	for _, l := range s.config.levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sampler) summarize() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.summaryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flushSummary()
+		case <-s.stop:
+			s.flushSummary()
+			return
+		}
+	}
+}
+
+func (s *Sampler) flushSummary() {
+	s.mu.Lock()
+	suppressed := s.suppressed
+	s.suppressed = map[sampleKey]*suppression{}
+	s.mu.Unlock()
+
+	for key, sup := range suppressed {
+		std.WithField(SuppressedField, sup.count).Logf(key.level, "Suppressed %d messages at %s, the last: %s", sup.count, key.site, sup.message)
+	}
+}
+
+var sampler atomic.Pointer[Sampler]
+
+// SetSampler sample the lines of the standard logger and the named loggers,
+// nil disable sampling.
+func SetSampler(s *Sampler) {
+	if prev := sampler.Swap(s); prev != nil && prev != s {
+		prev.Stop()
+	}
+}
+
+// sampledOutKey mark the entries not sampled, by the sampleGate fired before
+// the other hooks.
+const sampledOutKey = "_sampled_out"
+
+// sampleGate decide whether the entry is sampled, it's the first hook of the
+// standard logger so the decision is made before the other hooks fire.
+type sampleGate struct{}
+
+func (sampleGate) Levels() []Level {
+	return logrus.AllLevels
+}
+
+func (sampleGate) Fire(entry *logrus.Entry) error {
+	if s := sampler.Load(); s != nil && !s.Sample(entry) {
+		entry.Data[sampledOutKey] = true
+	}
+	return nil
+}
+
+func sampledOut(entry *logrus.Entry) bool {
+	_, ok := entry.Data[sampledOutKey]
+	return ok
+}
+
+// sampledHook skip the entries not sampled, it wraps the hooks added by
+// AddHook, eg. the sentry and metrics hooks.
+type sampledHook struct {
+	logrus.Hook
+}
+
+func (h sampledHook) Fire(entry *logrus.Entry) error {
+	if sampledOut(entry) {
+		return nil
+	}
+	return h.Hook.Fire(entry)
+}
+
+// sampledFormatter drop the lines not sampled, it wraps the formatter of the
+// standard logger.
+type sampledFormatter struct {
+	logrus.Formatter
+}
+
+func (f *sampledFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if sampledOut(entry) {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
@@ -0,0 +1,155 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleTest route the standard logger to a buffer sampled by s.
+func sampleTest(t *testing.T, s *Sampler) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetSampler(s)
+	t.Cleanup(func() {
+		SetSampler(nil)
+		SetOutput(os.Stderr)
+	})
+	return &buf
+}
+
+func countLines(buf *bytes.Buffer, substr string) int {
+	n := 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSamplerFirstThereafter(t *testing.T) {
+	tests := []struct {
+		first, thereafter int
+		lines             int
+		logged            []int
+	}{
+		{first: 2, thereafter: 3, lines: 10, logged: []int{1, 2, 5, 8}},
+		{first: 3, thereafter: 1, lines: 5, logged: []int{1, 2, 3, 4, 5}},
+		{first: 1, thereafter: 0, lines: 5, logged: []int{1}},
+		{first: 0, thereafter: 2, lines: 6, logged: []int{2, 4, 6}},
+	}
+	for _, tt := range tests {
+		s := NewSampler(tt.first, tt.thereafter, SampleWindow(time.Hour), SampleSummaryInterval(time.Hour))
+		buf := sampleTest(t, s)
+		// the values in the message vary, the lines are sampled by the call site.
+		for i := 1; i <= tt.lines; i++ {
+			Infof("sampled line=%d.", i)
+		}
+
+		var logged []int
+		for i := 1; i <= tt.lines; i++ {
+			if countLines(buf, "sampled line="+strconv.Itoa(i)+".") == 1 {
+				logged = append(logged, i)
+			}
+		}
+		if !equalInts(logged, tt.logged) {
+			t.Errorf("NewSampler(%d, %d) logged %v, want %v", tt.first, tt.thereafter, logged, tt.logged)
+		}
+
+		SetSampler(nil)
+		// the suppressed lines are summarized once stopped.
+		suppressed := tt.lines - len(tt.logged)
+		want := 0
+		if suppressed > 0 {
+			want = 1
+		}
+		summary := `"suppressed":` + strconv.Itoa(suppressed) + `}] Suppressed ` + strconv.Itoa(suppressed) + ` messages at `
+		if got := countLines(buf, summary); got != want {
+			t.Errorf("NewSampler(%d, %d) summary of %d suppressed logged %d times, want %d:\n%s", tt.first, tt.thereafter, suppressed, got, want, buf)
+		}
+	}
+}
+
+func TestSamplerKeys(t *testing.T) {
+	buf := sampleTest(t, NewSampler(1, 0, SampleWindow(time.Hour), SampleSummaryInterval(time.Hour)))
+
+	// the call sites are sampled apart, so are the levels.
+	for i := 0; i < 2; i++ {
+		Info("site a")
+	}
+	for i := 0; i < 2; i++ {
+		Info("site b")
+		Warn("site b")
+	}
+	// the explicit key shared by the call sites.
+	WithFields(Fields{SampleKeyField: "k"}).Info("key c")
+	WithFields(Fields{SampleKeyField: "k"}).Info("key c")
+
+	for _, tt := range []struct {
+		substr string
+		want   int
+	}{
+		{"site a", 1},
+		{"site b", 2},
+		{"[I ", 3},
+		{"key c", 1},
+	} {
+		if got := countLines(buf, tt.substr); got != tt.want {
+			t.Errorf("lines of %q = %d, want %d:\n%s", tt.substr, got, tt.want, buf)
+		}
+	}
+}
+
+func TestSamplerWindowReset(t *testing.T) {
+	s := NewSampler(2, 0, SampleWindow(time.Hour), SampleSummaryInterval(time.Hour))
+	buf := sampleTest(t, s)
+
+	log := func() {
+		for i := 0; i < 5; i++ {
+			Info("window line")
+		}
+	}
+	log()
+	if got := countLines(buf, "window line"); got != 2 {
+		t.Fatalf("lines in the first window = %d, want 2", got)
+	}
+
+	s.mu.Lock()
+	s.windowStart = s.windowStart.Add(-time.Hour)
+	s.mu.Unlock()
+	log()
+	if got := countLines(buf, "window line"); got != 4 {
+		t.Errorf("lines after the window reset = %d, want 4", got)
+	}
+}
+
+func TestSamplerLevels(t *testing.T) {
+	buf := sampleTest(t, NewSampler(1, 0, SampleLevels(WarnLevel), SampleWindow(time.Hour), SampleSummaryInterval(time.Hour)))
+	for i := 0; i < 3; i++ {
+		Info("info line")
+		Warn("warn line")
+	}
+	if got := countLines(buf, "info line"); got != 3 {
+		t.Errorf("info lines = %d, want 3 as not sampled", got)
+	}
+	if got := countLines(buf, "warn line"); got != 1 {
+		t.Errorf("warn lines = %d, want 1", got)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
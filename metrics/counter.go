@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"bufio"
+	"sync"
+)
+
+// Counter is a monotonically increasing value per label values.
+type Counter struct {
+	d *desc
+
+	mu     sync.Mutex
+	values map[string]*float64
+}
+
+// NewCounter register a counter to DefaultRegistry, or return the registered one.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labelNames...)
+}
+
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{
+		d:      newDesc(name, help, "counter", labelNames),
+		values: make(map[string]*float64),
+	}
+	return r.register(c).(*Counter)
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increase the counter by v, v must not be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := c.d.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	addValue(c.values, key, v)
+}
+
+func (c *Counter) desc() *desc {
+	return c.d
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeValues(w, c.d, c.values)
+}
+
+// Gauge is a value can go up and down per label values.
+type Gauge struct {
+	d *desc
+
+	mu     sync.Mutex
+	values map[string]*float64
+}
+
+// NewGauge register a gauge to DefaultRegistry, or return the registered one.
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labelNames...)
+}
+
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{
+		d:      newDesc(name, help, "gauge", labelNames),
+		values: make(map[string]*float64),
+	}
+	return r.register(g).(*Gauge)
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	key := g.d.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if p, ok := g.values[key]; ok {
+		*p = v
+		return
+	}
+	g.values[key] = &v
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	key := g.d.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	addValue(g.values, key, v)
+}
+
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *Gauge) desc() *desc {
+	return g.d
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	writeValues(w, g.d, g.values)
+}
+
+func addValue(values map[string]*float64, key string, v float64) {
+	if p, ok := values[key]; ok {
+		*p += v
+		return
+	}
+	values[key] = &v
+}
+
+func writeValues(w *bufio.Writer, d *desc, values map[string]*float64) {
+	for _, key := range sortedKeys(values) {
+		w.WriteString(d.name + d.labels(key) + " " + formatFloat(*values[key]) + "\n")
+	}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"bufio"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefBuckets is the default buckets in seconds, fit for the latency of rpc.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram count the observations in buckets per label values.
+type Histogram struct {
+	d       *desc
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // not cumulative, the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram register a histogram to DefaultRegistry, or return the
+// registered one. DefBuckets is used if buckets is empty.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labelNames...)
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	h := &Histogram{
+		d:       newDesc(name, help, "histogram", labelNames),
+		buckets: sorted,
+		series:  make(map[string]*histogramSeries),
+	}
+	return r.register(h).(*Histogram)
+}
+
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.d.key(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+// ObserveDuration observe the duration in seconds.
+func (h *Histogram) ObserveDuration(d time.Duration, labelValues ...string) {
+	h.Observe(d.Seconds(), labelValues...)
+}
+
+func (h *Histogram) desc() *desc {
+	return h.d
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			w.WriteString(h.d.name + "_bucket" + h.d.labels(key, "le", formatFloat(upper)) + " " + formatUint(cumulative) + "\n")
+		}
+		w.WriteString(h.d.name + "_bucket" + h.d.labels(key, "le", "+Inf") + " " + formatUint(s.count) + "\n")
+		w.WriteString(h.d.name + "_sum" + h.d.labels(key) + " " + formatFloat(s.sum) + "\n")
+		w.WriteString(h.d.name + "_count" + h.d.labels(key) + " " + formatUint(s.count) + "\n")
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+const textContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler expose the metrics of DefaultRegistry.
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// Handler expose the metrics in the prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", textContentType)
+		if err := r.WriteText(w); err != nil {
+			log.Errorf("Write metrics error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+// LogHook count the log lines of each level by a counter.
+type LogHook struct {
+	counters map[log.Level]*Counter
+}
+
+// NewLogHook count the warn and error lines by the named counters, eg.
+// "app.log.warn.count".
+func NewLogHook(warnMetric, errorMetric string) *LogHook {
+	return &LogHook{
+		counters: map[log.Level]*Counter{
+			log.WarnLevel:  NewCounter(warnMetric, "Number of warn log lines."),
+			log.ErrorLevel: NewCounter(errorMetric, "Number of error log lines."),
+		},
+	}
+}
+
+func (h *LogHook) Levels() []logrus.Level {
+	levels := make([]logrus.Level, 0, len(h.counters))
+	for level := range h.counters {
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+func (h *LogHook) Fire(entry *logrus.Entry) error {
+	if c, ok := h.counters[entry.Level]; ok {
+		c.Inc()
+	}
+	return nil
+}
+
+var (
+	stdLogHook     atomic.Pointer[LogHook]
+	stdLogHookOnce sync.Once
+)
+
+// CountLogLines count the warn and error lines of the standard logger by the
+// named counters. The hook is added once, a later call replaces the counters
+// rather than counting the lines twice.
+func CountLogLines(warnMetric, errorMetric string) {
+	stdLogHook.Store(NewLogHook(warnMetric, errorMetric))
+	stdLogHookOnce.Do(func() {
+		log.AddHook(currentLogHook{})
+	})
+}
+
+// currentLogHook fire the LogHook set by CountLogLines.
+type currentLogHook struct{}
+
+func (currentLogHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.WarnLevel, logrus.ErrorLevel}
+}
+
+func (currentLogHook) Fire(entry *logrus.Entry) error {
+	if h := stdLogHook.Load(); h != nil {
+		return h.Fire(entry)
+	}
+	return nil
+}
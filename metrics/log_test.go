@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+func counterValue(c *Counter) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[c.d.key(nil)]; ok {
+		return *v
+	}
+	return 0
+}
+
+func TestCountLogLines(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	// as by two applications, the lines are counted once.
+	CountLogLines("test.log.warn.count", "test.log.error.count")
+	CountLogLines("test.log.warn.count", "test.log.error.count")
+	log.Warn("warn")
+	log.Error("error")
+	log.Error("error")
+	log.Info("info")
+
+	warn := NewCounter("test.log.warn.count", "")
+	errs := NewCounter("test.log.error.count", "")
+	if got := counterValue(warn); got != 1 {
+		t.Errorf("warn count = %v, want 1", got)
+	}
+	if got := counterValue(errs); got != 2 {
+		t.Errorf("error count = %v, want 2", got)
+	}
+
+	// the later counters replace the earlier ones.
+	CountLogLines("test.log.warn2.count", "test.log.error2.count")
+	log.Warn("warn")
+	if got := counterValue(warn); got != 1 {
+		t.Errorf("replaced warn count = %v, want 1", got)
+	}
+	if got := counterValue(NewCounter("test.log.warn2.count", "")); got != 1 {
+		t.Errorf("warn2 count = %v, want 1", got)
+	}
+}
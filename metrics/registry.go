@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type collector interface {
+	desc() *desc
+	write(w *bufio.Writer)
+}
+
+// Registry hold the metrics to be exposed.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+// DefaultRegistry is used by the package level constructors.
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// register add c to the registry, or return the registered one with the same
+// name, it panics if the registered one is of different type or labels.
+func (r *Registry) register(c collector) collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := c.desc()
+	if exists, ok := r.collectors[d.name]; ok {
+		ed := exists.desc()
+		if ed.typ != d.typ || strings.Join(ed.labelNames, ",") != strings.Join(d.labelNames, ",") {
+			panic(fmt.Sprintf("metrics: %s registered as %s%v", d.name, ed.typ, ed.labelNames))
+		}
+		return exists
+	}
+	r.collectors[d.name] = c
+	return c
+}
+
+// WriteText write all metrics in the prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		d := c.desc()
+		if d.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", d.name, d.typ)
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+type desc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func newDesc(name, help, typ string, labelNames []string) *desc {
+	sanitized := make([]string, len(labelNames))
+	for i, l := range labelNames {
+		sanitized[i] = SanitizeName(l)
+	}
+	return &desc{
+		name:       SanitizeName(name),
+		help:       help,
+		typ:        typ,
+		labelNames: sanitized,
+	}
+}
+
+// key join label values as the key of a series, panic if the count mismatch.
+func (d *desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// labels format the label pairs of a series with optional extra pair, eg. le of histogram.
+func (d *desc) labels(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labelNames) > 0 {
+		values := strings.Split(key, "\xff")
+		for i, name := range d.labelNames {
+			pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var invalidNameChars = regexp.MustCompile("[^a-zA-Z0-9_:]")
+
+// SanitizeName replace the characters not allowed by prometheus with "_",
+// eg. "app.log.warn.count" to "app_log_warn_count".
+func SanitizeName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]*float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
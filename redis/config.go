@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/tomlconfig"
+)
+
+// section is the config section of a RWRedis, eg.
+//
+//	[redis.main]
+//	write = "redis://127.0.0.1:6379"
+//	read = ["redis://127.0.0.1:6380", "redis://127.0.0.1:6381"]
+//	max_idle = 10
+//	read_timeout = "200ms"
+type section struct {
+	Write             string        `toml:"write" validate:"required,url"`
+	Read              []string      `toml:"read" validate:"url"`
+	MaxIdle           int           `toml:"max_idle"`
+	MaxActive         int           `toml:"max_active"`
+	Wait              *bool         `toml:"wait"`
+	MaxConnLifetime   time.Duration `toml:"max_conn_lifetime"`
+	IdleTimeout       time.Duration `toml:"idle_timeout"`
+	ConnectTimeout    time.Duration `toml:"connect_timeout"`
+	WriteTimeout      time.Duration `toml:"write_timeout"`
+	ReadTimeout       time.Duration `toml:"read_timeout"`
+	Slowlog           time.Duration `toml:"slowlog"`
+	MaxRetryPerSecond int64         `toml:"max_retry_per_second"`
+	RetryOnTimeout    bool          `toml:"retry_on_timeout"`
+}
+
+func (s *section) options() []Option {
+	var opts []Option
+	if s.MaxIdle > 0 {
+		opts = append(opts, MaxIdle(s.MaxIdle))
+	}
+	if s.MaxActive > 0 {
+		opts = append(opts, MaxActive(s.MaxActive))
+	}
+	if s.Wait != nil {
+		opts = append(opts, Wait(*s.Wait))
+	}
+	if s.MaxConnLifetime > 0 {
+		opts = append(opts, MaxConnLifetime(s.MaxConnLifetime))
+	}
+	if s.IdleTimeout > 0 {
+		opts = append(opts, IdleTimeout(s.IdleTimeout))
+	}
+	if s.ConnectTimeout > 0 {
+		opts = append(opts, ConnectTimeout(s.ConnectTimeout))
+	}
+	if s.WriteTimeout > 0 {
+		opts = append(opts, WriteTimeout(s.WriteTimeout))
+	}
+	if s.ReadTimeout > 0 {
+		opts = append(opts, ReadTimeout(s.ReadTimeout))
+	}
+	if s.Slowlog > 0 {
+		opts = append(opts, Slowlog(s.Slowlog))
+	}
+	if s.MaxRetryPerSecond > 0 {
+		opts = append(opts, MaxRetryPerSecond(s.MaxRetryPerSecond))
+	}
+	if s.RetryOnTimeout {
+		opts = append(opts, RetryOnTimeout)
+	}
+	return opts
+}
+
+// NewRWRedisFromConfig create a RWRedis from the config section, eg.
+// app.Config().Sub("redis.main"), the options override the section.
+func NewRWRedisFromConfig(name string, conf *tomlconfig.Config, opts ...Option) (*RWRedis, error) {
+	var s section
+	if err := conf.Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode redis [%s] config error %s", name, err)
+	}
+	return NewRWRedis(name, s.Write, s.Read, append(s.options(), opts...)...), nil
+}
@@ -19,11 +19,16 @@ type Conn struct {
 }
 
 func (c *Conn) Do(ctx context.Context, cmd string, args ...interface{}) (reply interface{}, err error) {
+	start := time.Now()
+	defer func() {
+		observeCommand(c.tun, cmd, start, err)
+	}()
+
 	reply, err = c.rc.Do(cmd, args...)
 	if err != nil && (isConnectionReset(err) || isConnectionEOF(err)) && isIdempotentCommand(cmd) {
-		log.Error("retry on connection reset or eof: ", err)
+		log.ErrorContext(ctx, "retry on connection reset or eof: ", err)
 		if closeErr := c.rc.Close(); closeErr != nil {
-			log.Error(closeErr)
+			log.ErrorContext(ctx, closeErr)
 		}
 
 		c.rc = c.rp.Get()
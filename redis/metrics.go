@@ -0,0 +1,23 @@
+package redis
+
+import (
+	"strings"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/metrics"
+)
+
+var commandDurationSeconds = metrics.NewHistogram(
+	"redis_command_duration_seconds",
+	"Latency of redis commands.",
+	[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	"target", "command", "status",
+)
+
+func observeCommand(tun, cmd string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	commandDurationSeconds.ObserveDuration(time.Since(start), tun, strings.ToUpper(cmd), status)
+}
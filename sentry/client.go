@@ -0,0 +1,355 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+const (
+	sentryVersion = "7"
+	sentryClient  = "icafe-go/1.0"
+)
+
+// DSN is the parsed data source name, eg. https://public_key@host/project_id.
+type DSN struct {
+	raw       string
+	publicKey string
+	secretKey string
+	endpoint  string
+}
+
+func ParseDSN(raw string) (*DSN, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse sentry dsn")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid sentry dsn scheme: %s", u.Scheme)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("sentry dsn without public key: %s", raw)
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	idx := strings.LastIndex(path, "/")
+	projectID := path[idx+1:]
+	if projectID == "" {
+		return nil, fmt.Errorf("sentry dsn without project id: %s", raw)
+	}
+
+	secretKey, _ := u.User.Password()
+	return &DSN{
+		raw:       raw,
+		publicKey: u.User.Username(),
+		secretKey: secretKey,
+		endpoint:  fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, path[:idx], projectID),
+	}, nil
+}
+
+// Endpoint return the url the envelopes are posted to.
+func (d *DSN) Endpoint() string {
+	return d.endpoint
+}
+
+func (d *DSN) authHeader() string {
+	auth := fmt.Sprintf("Sentry sentry_version=%s, sentry_client=%s, sentry_key=%s", sentryVersion, sentryClient, d.publicKey)
+	if d.secretKey != "" {
+		auth += ", sentry_secret=" + d.secretKey
+	}
+	return auth
+}
+
+type dedupe struct {
+	firstSeen  time.Time
+	duplicates int64
+}
+
+// Client send the events to sentry in background, the events are dropped
+// rather than blocking the caller once the queue is full.
+type Client struct {
+	dsn        *DSN
+	config     *Config
+	httpClient *http.Client
+
+	queue   chan *Event
+	pending int64
+
+	mu            sync.Mutex
+	seen          map[string]*dedupe
+	disabledUntil time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func NewClient(dsn string, opts ...Option) (*Client, error) {
+	d, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	config := defaultConfig()
+	for _, o := range opts {
+		o(config)
+	}
+	if config.serverName == "" {
+		config.serverName, _ = os.Hostname()
+	}
+
+	c := &Client{
+		dsn:        d,
+		config:     config,
+		httpClient: &http.Client{Timeout: config.timeout},
+		queue:      make(chan *Event, config.queueSize),
+		seen:       map[string]*dedupe{},
+		done:       make(chan struct{}),
+	}
+	go c.run()
+	return c, nil
+}
+
+// CaptureException report the error with the stack of the caller, or the
+// stack carried by the error. The event id is returned, empty if dropped.
+func (c *Client) CaptureException(err error) string {
+	if err == nil {
+		return ""
+	}
+	event := NewEvent(LevelError)
+	event.Message = err.Error()
+	event.SetException(errorType(err), err.Error(), err, callers(2))
+	return c.CaptureEvent(event)
+}
+
+// CaptureMessage report the message with the stack of the caller.
+func (c *Client) CaptureMessage(level, message string) string {
+	event := NewEvent(level)
+	event.Message = message
+	return c.CaptureEvent(event)
+}
+
+// CapturePanic report the recovered value, it must be called in the deferred
+// function recovering the panic, so the stack of the panic is captured.
+func (c *Client) CapturePanic(recovered interface{}) string {
+	var err error
+	switch r := recovered.(type) {
+	case error:
+		err = r
+	default:
+		err = fmt.Errorf("%v", r)
+	}
+
+	event := NewEvent(LevelFatal)
+	event.Message = err.Error()
+	event.Tags["panic"] = "true"
+	event.SetException(errorType(err), err.Error(), err, panicStack(callers(2)))
+	return c.CaptureEvent(event)
+}
+
+// CaptureEvent fill the event with the config, and queue it to be sent.
+func (c *Client) CaptureEvent(event *Event) string {
+	event.filterFrames(c.config.includePaths)
+	if event.ServerName == "" {
+		event.ServerName = c.config.serverName
+	}
+	if event.Release == "" {
+		event.Release = c.config.release
+	}
+	if event.Environment == "" {
+		event.Environment = c.config.environment
+	}
+	for k, v := range c.config.tags {
+		if _, ok := event.Tags[k]; !ok {
+			event.Tags[k] = v
+		}
+	}
+
+	fingerprint := event.fingerprint()
+	if len(event.Fingerprint) == 0 {
+		event.Fingerprint = []string{fingerprint}
+	}
+	if duplicates, ok := c.dedupe(fingerprint); !ok {
+		return ""
+	} else if duplicates > 0 {
+		event.Extra["duplicates"] = duplicates
+	}
+
+	atomic.AddInt64(&c.pending, 1)
+	select {
+	case c.queue <- event:
+		return event.EventID
+	default:
+		atomic.AddInt64(&c.pending, -1)
+		return ""
+	}
+}
+
+// Flush wait until the queued events are sent, return false on timeout.
+func (c *Client) Flush(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt64(&c.pending) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// Close flush the queued events and stop sending.
+func (c *Client) Close(timeout time.Duration) bool {
+	ok := c.Flush(timeout)
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return ok
+}
+
+// dedupe report whether the event of fingerprint should be sent, and the
+// number of the events of it dropped before.
+func (c *Client) dedupe(fingerprint string) (int64, bool) {
+	if c.config.dedupeWindow <= 0 {
+		return 0, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	d, ok := c.seen[fingerprint]
+	if ok && now.Sub(d.firstSeen) < c.config.dedupeWindow {
+		d.duplicates++
+		return 0, false
+	}
+
+	var duplicates int64
+	if ok {
+		duplicates = d.duplicates
+	}
+	c.seen[fingerprint] = &dedupe{firstSeen: now}
+
+	// forget the fingerprints out of the window, so the map doesn't grow forever.
+	if len(c.seen) > 1000 {
+		for k, v := range c.seen {
+			if now.Sub(v.firstSeen) >= c.config.dedupeWindow && v.duplicates == 0 {
+				delete(c.seen, k)
+			}
+		}
+	}
+	return duplicates, true
+}
+
+func (c *Client) run() {
+	for {
+		select {
+		case event := <-c.queue:
+			if err := c.send(event); err != nil {
+				// never log at error level here, or the log hook reports it again.
+				log.Warnf("Send sentry event error: %v", err)
+			}
+			atomic.AddInt64(&c.pending, -1)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Client) send(event *Event) error {
+	c.mu.Lock()
+	disabled := time.Now().Before(c.disabledUntil)
+	c.mu.Unlock()
+	if disabled {
+		return errors.New("rate limited by sentry")
+	}
+
+	body, err := c.envelope(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.dsn.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", c.dsn.authHeader())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := time.Minute
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(s) * time.Second
+		}
+		c.mu.Lock()
+		c.disabledUntil = time.Now().Add(retryAfter)
+		c.mu.Unlock()
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry responded %s", resp.Status)
+	}
+	return nil
+}
+
+// envelope encode the event into an envelope with a single event item.
+func (c *Client) envelope(event *Event) ([]byte, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	header, err := json.Marshal(map[string]string{
+		"event_id": event.EventID,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+		"dsn":      c.dsn.raw,
+	})
+	if err != nil {
+		return nil, err
+	}
+	itemHeader, err := json.Marshal(map[string]interface{}{
+		"type":   "event",
+		"length": len(payload),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.WriteByte('\n')
+	buf.Write(itemHeader)
+	buf.WriteByte('\n')
+	buf.Write(payload)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// panicStack drop the frames of recovering, so the stack starts at the
+// function panicked.
+func panicStack(pcs []uintptr) []uintptr {
+	for i, pc := range pcs {
+		if fn := runtime.FuncForPC(pc - 1); fn != nil && fn.Name() == "runtime.gopanic" {
+			return pcs[i+1:]
+		}
+	}
+	return pcs
+}
@@ -0,0 +1,250 @@
+package sentry
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	pkgErrors "github.com/pkg/errors"
+)
+
+const (
+	LevelDebug   = "debug"
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+	LevelFatal   = "fatal"
+)
+
+// Event is the error event in the sentry event payload format.
+type Event struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Logger      string                 `json:"logger,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Fingerprint []string               `json:"fingerprint,omitempty"`
+	Exception   *Exceptions            `json:"exception,omitempty"`
+}
+
+type Exceptions struct {
+	Values []Exception `json:"values"`
+}
+
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+// Stacktrace hold the frames from the outermost caller to the innermost one.
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+type Frame struct {
+	Function string `json:"function,omitempty"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	AbsPath  string `json:"abs_path,omitempty"`
+	Lineno   int    `json:"lineno,omitempty"`
+	InApp    bool   `json:"in_app"`
+}
+
+// NewEvent create an event with a new id.
+func NewEvent(level string) *Event {
+	return &Event{
+		EventID:   newEventID(),
+		Timestamp: time.Now().UTC(),
+		Level:     level,
+		Platform:  "go",
+		Tags:      map[string]string{},
+		Extra:     map[string]interface{}{},
+	}
+}
+
+// SetException set the exception of the event, the frames are taken from err
+// if it carries a stack by github.com/pkg/errors, otherwise pcs is used.
+func (e *Event) SetException(typ, value string, err error, pcs []uintptr) {
+	if stack := stackOfError(err); stack != nil {
+		pcs = stack
+	}
+	exc := Exception{Type: typ, Value: value}
+	if len(pcs) > 0 {
+		exc.Stacktrace = &Stacktrace{Frames: framesOf(pcs)}
+	}
+	e.Exception = &Exceptions{Values: []Exception{exc}}
+}
+
+// filterFrames keep the frames in the include paths, all frames are kept if
+// none matches.
+func (e *Event) filterFrames(includePaths []string) {
+	if e.Exception == nil || len(includePaths) == 0 {
+		return
+	}
+	for i := range e.Exception.Values {
+		st := e.Exception.Values[i].Stacktrace
+		if st == nil {
+			continue
+		}
+		var kept []Frame
+		for _, f := range st.Frames {
+			if inPaths(f, includePaths) {
+				f.InApp = true
+				kept = append(kept, f)
+			}
+		}
+		if len(kept) > 0 {
+			st.Frames = kept
+		}
+	}
+}
+
+// fingerprint group the events by the exception type and the frames, or by
+// the message if there is no exception.
+func (e *Event) fingerprint() string {
+	h := sha1.New()
+	h.Write([]byte(e.Level))
+	if e.Exception == nil {
+		h.Write([]byte(e.Message))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	for _, exc := range e.Exception.Values {
+		h.Write([]byte(exc.Type))
+		if exc.Stacktrace == nil {
+			h.Write([]byte(exc.Value))
+			continue
+		}
+		for _, f := range exc.Stacktrace.Frames {
+			h.Write([]byte(f.Module + "." + f.Function))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func inPaths(f Frame, paths []string) bool {
+	for _, p := range paths {
+		if strings.Contains(f.Module, p) || strings.Contains(f.AbsPath, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// callers return the stack of the caller, skip is the same as runtime.Callers.
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	return pcs[:n]
+}
+
+// framesOf convert the program counters from the innermost caller to the
+// outermost one into frames in the reverse order.
+func framesOf(pcs []uintptr) []Frame {
+	var frames []Frame
+	iter := runtime.CallersFrames(pcs)
+	for {
+		f, more := iter.Next()
+		if f.Function != "" {
+			module, function := splitFunction(f.Function)
+			frames = append(frames, Frame{
+				Function: function,
+				Module:   module,
+				Filename: shortFile(f.File),
+				AbsPath:  f.File,
+				Lineno:   f.Line,
+				InApp:    !isStdModule(module),
+			})
+		}
+		if !more {
+			break
+		}
+	}
+
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// splitFunction split "github.com/a/b.(*T).M" into "github.com/a/b" and "(*T).M".
+func splitFunction(name string) (module, function string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
+
+func shortFile(file string) string {
+	parts := strings.Split(file, "/")
+	if len(parts) > 2 {
+		return strings.Join(parts[len(parts)-2:], "/")
+	}
+	return file
+}
+
+// isStdModule report whether the module is in the standard library, whose
+// first path element has no dot.
+func isStdModule(module string) bool {
+	first := strings.SplitN(module, "/", 2)[0]
+	return !strings.Contains(first, ".")
+}
+
+type stackTracer interface {
+	StackTrace() pkgErrors.StackTrace
+}
+
+// stackOfError return the innermost stack carried by the error chain.
+func stackOfError(err error) []uintptr {
+	var stack []uintptr
+	for err != nil {
+		if st, ok := err.(stackTracer); ok {
+			trace := st.StackTrace()
+			stack = make([]uintptr, len(trace))
+			for i, f := range trace {
+				stack[i] = uintptr(f)
+			}
+		}
+		if cause, ok := err.(interface{ Cause() error }); ok {
+			err = cause.Cause()
+		} else {
+			err = errors.Unwrap(err)
+		}
+	}
+	return stack
+}
+
+// errorType return the type name of the innermost error, eg. *net.OpError.
+func errorType(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if cause, ok := err.(interface{ Cause() error }); ok {
+			next = cause.Cause()
+		}
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = next
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
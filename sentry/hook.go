@@ -0,0 +1,95 @@
+package sentry
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+var (
+	logPackage    = reflect.TypeOf(log.Logger{}).PkgPath()
+	logrusPackage = reflect.TypeOf(logrus.Logger{}).PkgPath()
+	sentryPackage = reflect.TypeOf(Client{}).PkgPath()
+)
+
+// LogHook report the error log lines to sentry.
+type LogHook struct {
+	client *Client
+	levels []logrus.Level
+}
+
+// NewLogHook report the lines of error level and above.
+func NewLogHook(client *Client) *LogHook {
+	return &LogHook{
+		client: client,
+		levels: []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel},
+	}
+}
+
+func (h *LogHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+func (h *LogHook) Fire(entry *logrus.Entry) error {
+	level, typ := LevelError, "log.Error"
+	if entry.Level <= logrus.FatalLevel {
+		level, typ = LevelFatal, "log.Fatal"
+	}
+
+	event := NewEvent(level)
+	event.Timestamp = entry.Time.UTC()
+	event.Message = entry.Message
+	for k, v := range entry.Data {
+		switch k {
+		case log.LoggerField:
+			event.Logger = fmt.Sprint(v)
+		case log.AppField, log.TraceIDField, log.SpanIDField, log.RequestIDField:
+			event.Tags[k] = fmt.Sprint(v)
+		case logrus.ErrorKey:
+		default:
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			event.Extra[k] = v
+		}
+	}
+	if traceID, spanID := log.TraceFromContext(entry.Context); traceID != "" {
+		event.Tags[log.TraceIDField] = traceID
+		event.Tags[log.SpanIDField] = spanID
+	}
+	if requestID := log.RequestIDFromContext(entry.Context); requestID != "" {
+		event.Tags[log.RequestIDField] = requestID
+	}
+
+	pcs := logCallers()
+	if err, ok := entry.Data[logrus.ErrorKey].(error); ok {
+		event.SetException(errorType(err), entry.Message, err, pcs)
+	} else {
+		event.SetException(typ, entry.Message, nil, pcs)
+	}
+
+	h.client.CaptureEvent(event)
+	return nil
+}
+
+// logCallers return the stack of the log caller, the frames of logging are skipped.
+func logCallers() []uintptr {
+	pcs := callers(2)
+	for i, pc := range pcs {
+		fn := runtime.FuncForPC(pc - 1)
+		if fn == nil {
+			continue
+		}
+		name := fn.Name()
+		if !strings.HasPrefix(name, logPackage+".") && !strings.HasPrefix(name, logrusPackage+".") &&
+			!strings.HasPrefix(name, sentryPackage+".") {
+			return pcs[i:]
+		}
+	}
+	return pcs
+}
@@ -0,0 +1,71 @@
+package sentry
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+func TestLogHook(t *testing.T) {
+	s := newTestServer(t)
+	c := newTestClient(t, s)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.DebugLevel)
+	logger.AddHook(NewLogHook(c))
+
+	logger.Debug("debug line")
+	logger.Info("info line")
+	logger.Warn("warn line")
+	logger.WithFields(logrus.Fields{
+		log.LoggerField:    "db",
+		log.RequestIDField: "req-1",
+		"table":            "users",
+		logrus.ErrorKey:    errors.New("connection refused"),
+	}).Error("query failed")
+	if !c.Flush(5 * time.Second) {
+		t.Fatal("flush timeout")
+	}
+
+	events := s.received()
+	if len(events) != 1 {
+		t.Fatalf("%d events received, want only the error line", len(events))
+	}
+	e := events[0]
+	if e.Level != LevelError || e.Message != "query failed" || e.Logger != "db" ||
+		e.Tags[log.RequestIDField] != "req-1" || e.Extra["table"] != "users" {
+		t.Errorf("event = %+v", e)
+	}
+	if _, ok := e.Extra[logrus.ErrorKey]; ok {
+		t.Errorf("the error is sent as extra: %+v", e.Extra)
+	}
+
+	exc := e.Exception.Values[0]
+	if exc.Type != "*errors.errorString" || exc.Value != "query failed" || exc.Stacktrace == nil {
+		t.Fatalf("exception = %+v", exc)
+	}
+	// the frames of logging are skipped, and of this package as well.
+	for _, f := range exc.Stacktrace.Frames {
+		if f.Module == logrusPackage || f.Module == logPackage || f.Module == sentryPackage {
+			t.Errorf("frame of logging kept: %+v", f)
+		}
+	}
+}
+
+func TestLogHookLevels(t *testing.T) {
+	levels := map[logrus.Level]bool{}
+	for _, l := range NewLogHook(nil).Levels() {
+		levels[l] = true
+	}
+	for _, l := range logrus.AllLevels {
+		if want := l <= logrus.ErrorLevel; levels[l] != want {
+			t.Errorf("level %s reported %t, want %t", l, levels[l], want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sentry
+
+import "time"
+
+type Config struct {
+	includePaths []string
+	environment  string
+	release      string
+	serverName   string
+	tags         map[string]string
+	queueSize    int
+	timeout      time.Duration
+	dedupeWindow time.Duration
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		queueSize:    100,
+		timeout:      5 * time.Second,
+		dedupeWindow: time.Minute,
+		tags:         map[string]string{},
+	}
+}
+
+type Option func(*Config)
+
+// IncludePaths keep only the stack frames of the packages or files containing
+// one of the paths, eg. "github.com/YLeseclaireurs/icafe". All frames are
+// kept if none matches.
+// Default: all frames
+func IncludePaths(paths ...string) Option {
+	return func(c *Config) {
+		c.includePaths = paths
+	}
+}
+
+func Environment(env string) Option {
+	return func(c *Config) {
+		c.environment = env
+	}
+}
+
+func Release(release string) Option {
+	return func(c *Config) {
+		c.release = release
+	}
+}
+
+// ServerName set the server name of the events.
+// Default: hostname
+func ServerName(name string) Option {
+	return func(c *Config) {
+		c.serverName = name
+	}
+}
+
+// Tag add a tag to every event.
+func Tag(key, value string) Option {
+	return func(c *Config) {
+		c.tags[key] = value
+	}
+}
+
+// QueueSize set the max events waiting to be sent, the events beyond are dropped.
+// Default: 100
+func QueueSize(n int) Option {
+	return func(c *Config) {
+		c.queueSize = n
+	}
+}
+
+// Timeout set the timeout to send an event.
+// Default: 5s
+func Timeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.timeout = d
+	}
+}
+
+// DedupeWindow send an event of the same fingerprint at most once in the
+// window, the number of the events dropped is sent with the next one as
+// extra "duplicates". 0 disable deduplication.
+// Default: 1m
+func DedupeWindow(d time.Duration) Option {
+	return func(c *Config) {
+		c.dedupeWindow = d
+	}
+}
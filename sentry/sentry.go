@@ -0,0 +1,50 @@
+package sentry
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var defaultClient atomic.Pointer[Client]
+
+// SetDefault set the client used by the package level functions.
+func SetDefault(c *Client) {
+	defaultClient.Store(c)
+}
+
+// Default return the client set by SetDefault, nil if not set.
+func Default() *Client {
+	return defaultClient.Load()
+}
+
+// CaptureException report the error by the default client, if any.
+func CaptureException(err error) string {
+	if c := Default(); c != nil {
+		return c.CaptureException(err)
+	}
+	return ""
+}
+
+// CaptureMessage report the message by the default client, if any.
+func CaptureMessage(level, message string) string {
+	if c := Default(); c != nil {
+		return c.CaptureMessage(level, message)
+	}
+	return ""
+}
+
+// CapturePanic report the recovered value by the default client, if any.
+func CapturePanic(recovered interface{}) string {
+	if c := Default(); c != nil {
+		return c.CapturePanic(recovered)
+	}
+	return ""
+}
+
+// Flush wait until the events queued in the default client are sent.
+func Flush(timeout time.Duration) bool {
+	if c := Default(); c != nil {
+		return c.Flush(timeout)
+	}
+	return true
+}
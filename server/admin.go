@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
+	"github.com/YLeseclaireurs/icafe/metrics"
+)
+
+// The paths served by the admin server.
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+	bundlesPath   = "/bundles"
+)
+
+// adminStatus is the response of the bundles path.
+type adminStatus struct {
+	App     string         `json:"app"`
+	Ready   bool           `json:"ready"`
+	Reason  string         `json:"reason,omitempty"`
+	Bundles []BundleStatus `json:"bundles"`
+}
+
+// AdminHandler return the handler of the admin server:
+//
+//	/healthz        liveness, ok as long as the process serves
+//	/readyz         readiness, 503 before all bundles ready or once stopping
+//	/bundles        type, name, state and uptime of the bundles in JSON
+//	/metrics        the metrics
+//	/debug/pprof/   the pprof handlers
+func (app *BaseApplication) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(livenessPath, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc(readinessPath, func(w http.ResponseWriter, _ *http.Request) {
+		if reason := app.notReady(); reason != "" {
+			http.Error(w, "not ready: "+reason, http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc(bundlesPath, func(w http.ResponseWriter, _ *http.Request) {
+		reason := app.notReady()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(adminStatus{
+			App:     app.name,
+			Ready:   reason == "",
+			Reason:  reason,
+			Bundles: app.BundleStatuses(),
+		})
+	})
+	mux.Handle(metricsPath, metrics.Handler())
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return mux
+}
+
+// notReady return why the application is not ready, empty if ready.
+func (app *BaseApplication) notReady() string {
+	switch {
+	case app.stopping.Load():
+		return "stopping"
+	case !app.started.Load():
+		return "starting"
+	}
+	for _, status := range app.BundleStatuses() {
+		switch status.State {
+		case BundleFailed, BundleSkipped:
+			return fmt.Sprintf("bundle %s[%s] %s: %s", status.Type, status.Name, status.State, status.Error)
+		case BundleStarting, BundleRestarting:
+			return fmt.Sprintf("bundle %s[%s] %s", status.Type, status.Name, status.State)
+		}
+	}
+	return ""
+}
+
+// startAdmin serve the admin handler in background if WithAdmin is given.
+func (app *BaseApplication) startAdmin() {
+	if app.config.adminPort == nil {
+		return
+	}
+
+	app.adminServer = &http.Server{
+		Addr:              fmt.Sprintf(":%d", *app.config.adminPort),
+		Handler:           app.AdminHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := app.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.ErrorContext(app.ctx, "Start admin server error: ", err)
+		}
+	}()
+}
+
+func (app *BaseApplication) stopAdmin() {
+	if app.adminServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := app.adminServer.Shutdown(ctx); err != nil {
+		log.ErrorContext(app.ctx, "Stop admin server error: ", err)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatedBundle is ready once the gate is closed.
+type gatedBundle struct {
+	*testBundle
+	gate chan struct{}
+}
+
+func (b *gatedBundle) Ready() <-chan struct{} { return b.gate }
+
+func adminGet(h http.Handler, path string) (int, string) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, strings.TrimSpace(rec.Body.String())
+}
+
+func TestAdminReadiness(t *testing.T) {
+	stopping, release := make(chan struct{}), make(chan struct{})
+	app := NewApplication(Name("admin-test"), BeforeStop(func(ctx context.Context) error {
+		close(stopping)
+		<-release
+		return nil
+	}))
+	events := &testEvents{}
+	bundle := &gatedBundle{testBundle: newTestBundle("api", events, 0), gate: make(chan struct{})}
+	app.AddBundle(bundle)
+	h := app.AdminHandler()
+
+	check := func(phase string, wantCode int, wantBody string) {
+		t.Helper()
+		if code, body := adminGet(h, livenessPath); code != http.StatusOK || body != "ok" {
+			t.Errorf("%s: %s = %d %q, want 200 ok", phase, livenessPath, code, body)
+		}
+		if code, body := adminGet(h, readinessPath); code != wantCode || body != wantBody {
+			t.Errorf("%s: %s = %d %q, want %d %q", phase, readinessPath, code, body, wantCode, wantBody)
+		}
+
+		code, body := adminGet(h, bundlesPath)
+		var status adminStatus
+		if err := json.Unmarshal([]byte(body), &status); err != nil || code != http.StatusOK {
+			t.Fatalf("%s: %s = %d %q", phase, bundlesPath, code, body)
+		}
+		if status.App != "admin-test" || status.Ready != (wantCode == http.StatusOK) ||
+			len(status.Bundles) != 1 || status.Bundles[0].Name != "api" {
+			t.Errorf("%s: %s = %s", phase, bundlesPath, body)
+		}
+	}
+
+	check("before run", http.StatusServiceUnavailable, "not ready: starting")
+
+	returned := make(chan error, 1)
+	go func() {
+		returned <- app.Run()
+	}()
+	waitUntil(t, "the bundle run", func() bool { return events.index("run api") >= 0 })
+	check("bundle not ready", http.StatusServiceUnavailable, "not ready: starting")
+
+	close(bundle.gate)
+	waitUntil(t, "ready", func() bool {
+		code, _ := adminGet(h, readinessPath)
+		return code == http.StatusOK
+	})
+	check("ready", http.StatusOK, "ok")
+
+	// the bundle finished, the application stops.
+	bundle.Stop()
+	select {
+	case <-stopping:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the application not stopping")
+	}
+	check("stopping", http.StatusServiceUnavailable, "not ready: stopping")
+
+	close(release)
+	select {
+	case err := <-returned:
+		if err != nil {
+			t.Errorf("Run error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run not returned")
+	}
+	check("stopped", http.StatusServiceUnavailable, "not ready: stopping")
+}
+
+// waitUntil poll cond until true, or fail the test.
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
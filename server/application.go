@@ -2,22 +2,29 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/YLeseclaireurs/icafe/log"
+	"github.com/YLeseclaireurs/icafe/metrics"
+	"github.com/YLeseclaireurs/icafe/sentry"
+	"github.com/YLeseclaireurs/icafe/tomlconfig"
 	"github.com/YLeseclaireurs/icafe/utils"
 	//nolint:gosec
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
 type Application interface {
 	Name() string
-	Run()
+	Config() *tomlconfig.Config
+	Run() error
 	AddBundle(bundles ...Bundle)
 }
 
@@ -28,19 +35,40 @@ type BaseApplication struct {
 	ctx  context.Context
 
 	// config
-	config appConfig
+	config      appConfig
+	conf        *tomlconfig.Config
+	confOpts    []tomlconfig.Option
+	confWatcher *tomlconfig.Watcher
+
+	// error reporting
+	reporter *sentry.Client
+
+	// readiness reported by the admin server
+	adminServer *http.Server
+	started     atomic.Bool
+	stopping    atomic.Bool
+
+	// service registration
+	registrar     Registrar
+	instances     []*Instance
+	stopHeartbeat context.CancelFunc
 
 	// lifecycle hooks
-	beforeStart []func(ctx context.Context) error
-	afterStart  []func(ctx context.Context) error
-	beforeStop  []func(ctx context.Context) error
-	afterStop   []func(ctx context.Context) error
+	beforeStart []hook
+	afterStart  []hook
+	beforeStop  []hook
+	afterStop   []hook
 }
 
 type appContextKeyType string
 
 const appContextKey appContextKeyType = "app-context"
 
+const metricsPath = "/metrics"
+
+// registerMetricsOnce guard the metrics handler registered to the default mux for pprof.
+var registerMetricsOnce sync.Once
+
 func NewApplication(opts ...Option) *BaseApplication {
 	defaults := getDefaults()
 	customOptions := &options{}
@@ -51,6 +79,15 @@ func NewApplication(opts ...Option) *BaseApplication {
 	ctx := utils.DerefCtx(customOptions.ctx, context.Background())
 	appName := utils.DerefString(customOptions.appName, defaults.appName)
 
+	logLevel := defaults.logLevel
+	if customOptions.logLevel != nil {
+		logLevel = *customOptions.logLevel
+	}
+	var logSample *log.Sampler
+	if customOptions.logSample != nil {
+		logSample = customOptions.logSample()
+	}
+
 	app := &BaseApplication{
 		Container: *New(),
 		name:      appName,
@@ -58,11 +95,31 @@ func NewApplication(opts ...Option) *BaseApplication {
 			warnMetric:   defaultWarnLogMetric(appName),
 			errorMetric:  defaultErrorLogMetric(appName),
 			profilerPort: customOptions.profilerPort,
+			metricsPort:  customOptions.metricsPort,
+			adminPort:    customOptions.adminPort,
 			enableConfig: customOptions.withConfig,
+			watchConfig:  customOptions.watchConfig,
+			sentryDSN:    utils.DerefString(customOptions.sentryDSN, ""),
 			includePaths: customOptions.includePaths,
+
+			logLevel:  logLevel,
+			logFormat: utils.DerefString(customOptions.logFormat, defaults.logFormat),
+			logCaller: utils.DerefBool(customOptions.logCaller, false),
+			logFile:   utils.DerefString(customOptions.logFile, ""),
+			logRotate: customOptions.logRotate,
+			logSample: logSample,
+
+			heartbeatInterval: utils.DerefDuration(customOptions.heartbeatInterval, defaults.heartbeatInterval),
+			shutdownTimeout:   utils.DerefDuration(customOptions.shutdownTimeout, defaults.shutdownTimeout),
+			hookParallel:      customOptions.hookParallel,
 		},
 		ctx: ctx,
 
+		confOpts: customOptions.configOpts,
+
+		// service registration
+		registrar: customOptions.registrar,
+
 		// hooks
 		beforeStart: customOptions.beforeStart,
 		afterStart:  customOptions.afterStart,
@@ -70,7 +127,16 @@ func NewApplication(opts ...Option) *BaseApplication {
 		afterStop:   customOptions.afterStop,
 	}
 
+	if customOptions.hookPolicy != nil {
+		app.config.hookPolicy = *customOptions.hookPolicy
+	}
+	if customOptions.failurePolicy != nil {
+		app.SetDefaultPolicy(*customOptions.failurePolicy)
+	}
+
 	app.initLog()
+	app.initSentry()
+	app.initConfig()
 
 	return app
 }
@@ -82,7 +148,7 @@ func AppFromContext(ctx context.Context) Application {
 			return app
 		}
 	}
-	log.Error(ctx, "Not a valid cafe context")
+	log.ErrorContext(ctx, "Not a valid cafe context")
 	return nil
 }
 
@@ -90,82 +156,321 @@ func (app *BaseApplication) Name() string {
 	return app.name
 }
 
-func (app *BaseApplication) runBeforeStart() {
-	if err := RunUntilError(app.ctx, app.beforeStart); err != nil {
-		log.Error(app.ctx, "Error run before start hook:", err)
+// Config return the config loaded by WithConfig, nil if not enabled. The
+// latest config is returned if it's watched.
+func (app *BaseApplication) Config() *tomlconfig.Config {
+	if app.confWatcher != nil {
+		return app.confWatcher.Config()
 	}
+	return app.conf
+}
+
+// ConfigWatcher return the watcher of the config enabled by WatchConfig, to
+// subscribe the changes.
+func (app *BaseApplication) ConfigWatcher() *tomlconfig.Watcher {
+	return app.confWatcher
+}
+
+func (app *BaseApplication) runBeforeStart() error {
+	return app.runHooks("before start", app.beforeStart)
+}
+
+func (app *BaseApplication) runAfterStart() error {
+	return app.runHooks("after start", app.afterStart)
+}
+
+func (app *BaseApplication) runBeforeStop() error {
+	app.stopping.Store(true)
+	app.deregisterBundles()
+
+	return app.runHooks("before stop", app.beforeStop)
 }
 
-func (app *BaseApplication) runAfterStart() {
-	if err := RunUntilError(app.ctx, app.afterStart); err != nil {
-		log.Error(app.ctx, "Error run after start hook:", err)
+func (app *BaseApplication) runAfterStop() error {
+	return app.runHooks("after stop", app.afterStop)
+}
+
+// runHooks run the hooks of a phase, the error is logged, and returned if
+// the policy is FailFast.
+func (app *BaseApplication) runHooks(phase string, hooks []hook) error {
+	err := runHooks(app.ctx, hooks, app.config.hookParallel)
+	if err == nil {
+		return nil
+	}
+	log.ErrorContextf(app.ctx, "Error run %s hook: %v", phase, err)
+	if app.config.hookPolicy == ContinueOnError {
+		return nil
 	}
+	return fmt.Errorf("%s hook: %w", phase, err)
 }
 
-func (app *BaseApplication) runBeforeStop() {
-	if err := RunUntilError(app.ctx, app.beforeStop); err != nil {
-		log.Error(app.ctx, "Error run before stopAll hook:", err)
+// registerBundles announce the listening bundles to the registrar once they
+// are ready, and keep sending heartbeat until deregistered. The bundles not
+// running, eg. failed to listen, are not announced.
+func (app *BaseApplication) registerBundles() {
+	if app.registrar == nil {
+		return
+	}
+
+	for i, b := range app.bundles {
+		l, ok := b.(Listener)
+		if !ok {
+			continue
+		}
+		if !app.isState(i, BundleRunning) {
+			log.WarnContextf(app.ctx, "Skip registering bundle:%s not running", bundleDesc(b))
+			continue
+		}
+		addr, err := advertiseAddr(l.ListenAddr())
+		if err != nil {
+			log.ErrorContextf(app.ctx, "Resolve advertise address of bundle:%s error: %v", bundleDesc(b), err)
+			continue
+		}
+		instance := &Instance{Name: b.Name(), Type: b.Type(), Address: addr}
+		if err := app.registrar.Register(app.ctx, instance); err != nil {
+			log.ErrorContextf(app.ctx, "Register bundle:%s error: %v", bundleDesc(b), err)
+			continue
+		}
+		log.InfoContextf(app.ctx, "Bundle registered:%s address=%s", bundleDesc(b), addr)
+		app.instances = append(app.instances, instance)
 	}
+
+	instances := app.instances
+	ctx, cancel := context.WithCancel(app.ctx)
+	app.stopHeartbeat = cancel
+	go func() {
+		ticker := time.NewTicker(app.config.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, instance := range instances {
+					if err := app.registrar.Heartbeat(ctx, instance); err != nil {
+						log.ErrorContextf(ctx, "Heartbeat %s[%s] error: %v", instance.Type, instance.Name, err)
+					}
+				}
+			}
+		}
+	}()
 }
 
-func (app *BaseApplication) runAfterStop() {
-	if err := RunUntilError(app.ctx, app.afterStop); err != nil {
-		log.Error(app.ctx, "Error run after stopAll hook:", err)
+// deregisterBundles remove the bundles from registrar before the traffic is drained.
+func (app *BaseApplication) deregisterBundles() {
+	if app.registrar == nil {
+		return
 	}
+
+	if app.stopHeartbeat != nil {
+		app.stopHeartbeat()
+	}
+	for _, instance := range app.instances {
+		if err := app.registrar.Deregister(app.ctx, instance); err != nil {
+			log.ErrorContextf(app.ctx, "Deregister %s[%s] error: %v", instance.Type, instance.Name, err)
+		}
+	}
+	app.instances = nil
 }
 
 func (app *BaseApplication) initLog() {
-	//if zae.IsDevelopEnv() {
-	log.SetLevel(log.DebugLevel)
-	//}
+	log.SetLevel(app.config.logLevel)
+	if err := log.SetFormat(app.config.logFormat); err != nil {
+		log.Error("Set log format error: ", err)
+	}
+	log.SetReportCaller(app.config.logCaller)
+	if app.config.logSample != nil {
+		log.SetSampler(app.config.logSample)
+	}
+	if app.config.logFile != "" {
+		if err := log.SetOutputFile(app.config.logFile, app.config.logRotate...); err != nil {
+			log.Error("Set log file error: ", err)
+		}
+	}
+
+	log.SetAppName(app.name)
+	metrics.CountLogLines(app.config.warnMetric, app.config.errorMetric)
 }
 
-func (app *BaseApplication) Run() {
-	log.Infof("Run cafe application,name=%s", app.name)
+func (app *BaseApplication) initConfig() {
+	if !app.config.enableConfig {
+		return
+	}
 
+	if app.config.watchConfig {
+		watcher, err := tomlconfig.NewWatcher(app.confOpts...)
+		if err != nil {
+			log.Fatalf("Load config error: %v", err)
+		}
+		log.Infof("Config loaded from %v, watching changes", watcher.Config().Files())
+		app.confWatcher = watcher
+		return
+	}
+
+	conf, err := tomlconfig.Load(app.confOpts...)
+	if err != nil {
+		log.Fatalf("Load config error: %v", err)
+	}
+	log.Infof("Config loaded from %v", conf.Files())
+	app.conf = conf
+}
+
+// initSentry report the error log lines and the panics recovered by
+// utils.SafelyRun, if a sentry dsn is given.
+func (app *BaseApplication) initSentry() {
+	if app.config.sentryDSN == "" {
+		return
+	}
+
+	client, err := sentry.NewClient(app.config.sentryDSN,
+		sentry.IncludePaths(app.config.includePaths...),
+		sentry.Tag(log.AppField, app.name),
+	)
+	if err != nil {
+		log.Warn("Init sentry error: ", err)
+		return
+	}
+
+	app.reporter = client
+	sentry.SetDefault(client)
+	log.AddHook(sentry.NewLogHook(client))
+	utils.SetPanicHandler(func(recovered interface{}) {
+		client.CapturePanic(recovered)
+	})
+}
+
+// Run the bundles until all finished, a shutdown signal received or a bundle
+// failure shutting down all, see FailurePolicy. It returns the bundle failure
+// and the hook errors if FailFast, see ExitCode.
+func (app *BaseApplication) Run() error {
 	// 这个时候才知道应用的 application 对象是什么
 	app.ctx = context.WithValue(app.ctx, appContextKey, app)
 
+	log.InfoContextf(app.ctx, "Run cafe application,name=%s", app.name)
+
+	app.startAdmin()
+
 	if app.config.profilerPort != nil {
+		registerMetricsOnce.Do(func() {
+			http.Handle(metricsPath, metrics.Handler())
+		})
 		go func() {
 			err := http.ListenAndServe(fmt.Sprintf(":%d", *app.config.profilerPort), nil)
 			if err != nil {
-				log.Error(app.ctx, "Start pprof error: ", err)
+				log.ErrorContext(app.ctx, "Start pprof error: ", err)
 			}
 		}()
 	}
 
-	// start all
-	app.runBeforeStart()
+	if app.config.metricsPort != nil {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle(metricsPath, metrics.Handler())
+			err := http.ListenAndServe(fmt.Sprintf(":%d", *app.config.metricsPort), mux)
+			if err != nil {
+				log.ErrorContext(app.ctx, "Start metrics error: ", err)
+			}
+		}()
+	}
 
-	finishCtx := app.StartAll(app.ctx)
+	if app.confWatcher != nil {
+		app.confWatcher.Start()
+	}
+
+	err := app.run()
+
+	if app.confWatcher != nil {
+		app.confWatcher.Stop()
+	}
+
+	app.stopAdmin()
+
+	if err != nil {
+		log.ErrorContext(app.ctx, "Application exit with error: ", err)
+	}
+	log.InfoContext(app.ctx, "Bye!")
+
+	log.SetSampler(nil)
+
+	if app.reporter != nil && !app.reporter.Close(5*time.Second) {
+		log.Warn("Flush sentry events timeout")
+	}
 
-	app.runAfterStart()
+	if closeErr := log.Close(); closeErr != nil {
+		fmt.Fprintf(os.Stderr, "Close log error: %v\n", closeErr)
+	}
+	return err
+}
 
+// run start the bundles with the hooks, wait for the shutdown, then stop them.
+func (app *BaseApplication) run() error {
 	// wait for shutdown or done
 	shutdownSignal := make(chan os.Signal, 1)
 	signal.Notify(shutdownSignal, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	defer signal.Stop(shutdownSignal)
+
+	// start all
+	if err := app.runBeforeStart(); err != nil {
+		log.ErrorContext(app.ctx, "Abort starting")
+		return err
+	}
+
+	finishCtx := app.StartAll(app.ctx)
+
+	var errs []error
 	select {
+	case <-app.BundlesReady():
+		app.started.Store(true)
+
+		app.registerBundles()
+
+		if err := app.runAfterStart(); err != nil {
+			log.ErrorContext(app.ctx, "Abort starting")
+			errs = append(errs, err)
+			break
+		}
+
+		select {
+		case <-finishCtx.Done():
+			log.InfoContext(app.ctx, "All bundle finished!")
+		case <-shutdownSignal:
+			log.InfoContext(app.ctx, "Shutdown signal received")
+		case <-app.Failed():
+			log.ErrorContext(app.ctx, "Shutdown by failure: ", app.Failure())
+		}
 	case <-finishCtx.Done():
-		log.Info(app.ctx, "All bundle finished!")
+		log.InfoContext(app.ctx, "All bundle finished!")
 	case <-shutdownSignal:
-		log.Info(app.ctx, "Shutdown signal received")
+		log.InfoContext(app.ctx, "Shutdown signal received while starting")
+	case <-app.Failed():
+		log.ErrorContext(app.ctx, "Shutdown by failure while starting: ", app.Failure())
 	}
 
 	// stop all
-	app.runBeforeStop()
+	errs = append(errs, app.runBeforeStop())
 
 	ctx := app.StopAll(app.ctx)
 
-	shutdownTimeout := time.After(30 * time.Second)
+	shutdownTimeout := time.NewTimer(app.config.shutdownTimeout)
+	defer shutdownTimeout.Stop()
 	select {
 	case <-ctx.Done():
-		log.Info(app.ctx, "Application stopped")
-	case <-shutdownTimeout:
-		log.Info(app.ctx, "Shutdown timeout, force stop application")
+		log.InfoContext(app.ctx, "Application stopped")
+	case <-shutdownTimeout.C:
+		log.InfoContext(app.ctx, "Shutdown timeout, force stop application")
 	}
 
-	app.runAfterStop()
+	errs = append(errs, app.runAfterStop())
 
-	log.Info(app.ctx, "Bye!")
+	return errors.Join(append([]error{app.Failure()}, errs...)...)
+}
+
+// ExitCode return the exit code of the error returned by Run, eg.
+//
+//	os.Exit(server.ExitCode(app.Run()))
+func ExitCode(err error) int {
+	if err != nil {
+		return 1
+	}
+	return 0
 }
@@ -1,14 +1,42 @@
 package server
 
+import (
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
 type appConfig struct {
 	// log monitor
 	warnMetric  string
 	errorMetric string
 
+	// log
+	logLevel  log.Level
+	logFormat string
+	logCaller bool
+	logFile   string
+	logRotate []log.RotateOption
+	logSample *log.Sampler
+
 	// pprof
 	profilerPort *int
 
+	// metrics
+	metricsPort *int
+
+	// admin
+	adminPort *int
+
 	enableConfig bool
+	watchConfig  bool
 
+	sentryDSN    string
 	includePaths []string
+
+	heartbeatInterval time.Duration
+	shutdownTimeout   time.Duration
+
+	hookPolicy   HookPolicy
+	hookParallel bool
 }
@@ -2,8 +2,11 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/YLeseclaireurs/icafe/utils"
 	"sync"
+	"time"
 
 	"github.com/YLeseclaireurs/icafe/log"
 )
@@ -15,13 +18,89 @@ type Bundle interface {
 	Stop() context.Context
 }
 
+// Readier is implemented by bundles taking a while to be ready after Run is
+// called, eg. a connection pool warming up, the dependents are started once
+// the channel is closed. Other bundles are ready once Run is called.
+type Readier interface {
+	Ready() <-chan struct{}
+}
+
+// The states of a bundle.
+const (
+	BundleIdle       = "idle"
+	BundleStarting   = "starting"
+	BundleRunning    = "running"
+	BundleFinished   = "finished"
+	BundleFailed     = "failed"
+	BundleRestarting = "restarting"
+	BundleSkipped    = "skipped"
+	BundleStopping   = "stopping"
+	BundleStopped    = "stopped"
+)
+
+// BundleStatus is the state of a bundle reported by the admin server.
+type BundleStatus struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	State     string   `json:"state"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	// StartedAt is nil if the bundle never started.
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	Uptime    string     `json:"uptime,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Policy    string     `json:"policy"`
+	Restarts  int        `json:"restarts"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type bundleState struct {
+	state     string
+	startedAt time.Time
+	endedAt   time.Time
+	err       error
+
+	// policy is nil for the default one.
+	policy   *FailurePolicy
+	restarts int
+	lastErr  error
+
+	// deps is the indexes of the bundles depended on.
+	deps []int
+	// settled is closed once the bundle is ready, or never will be.
+	settled   chan struct{}
+	ready     bool
+	settleOne sync.Once
+	// stopped is closed once the bundle stopped.
+	stopped chan struct{}
+}
+
+var errStoppedBeforeStart = errors.New("stopped before started")
+
 type Container struct {
 	bundles  []Bundle
 	bundleWg sync.WaitGroup
+
+	stateMu sync.RWMutex
+	states  []*bundleState
+	// allSettled is closed once all bundles settled.
+	allSettled chan struct{}
+
+	defaultPolicy FailurePolicy
+	// failed is closed with failure once a bundle failure shuts down all.
+	failed   chan struct{}
+	failure  error
+	failOnce sync.Once
+	// stopping is closed once StopAll called.
+	stopping chan struct{}
+	stopOnce sync.Once
 }
 
 func New() *Container {
-	return &Container{}
+	return &Container{
+		allSettled: make(chan struct{}),
+		failed:     make(chan struct{}),
+		stopping:   make(chan struct{}),
+	}
 }
 
 func bundleDesc(b Bundle) string {
@@ -29,25 +108,282 @@ func bundleDesc(b Bundle) string {
 }
 
 func (c *Container) AddBundle(bundles ...Bundle) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
 	c.bundles = append(c.bundles, bundles...)
+	for range bundles {
+		c.states = append(c.states, &bundleState{
+			state:   BundleIdle,
+			settled: make(chan struct{}),
+			stopped: make(chan struct{}),
+		})
+	}
+}
+
+// DependsOn declare that bundle depends on deps, so it's started after deps
+// are ready and stopped before deps are stopped, eg. stop the gRPC server
+// before closing the redis pools. All of them must be added already.
+func (c *Container) DependsOn(bundle Bundle, deps ...Bundle) error {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	i := c.indexOf(bundle)
+	if i < 0 {
+		return fmt.Errorf("bundle %s not added", bundleDesc(bundle))
+	}
+	for _, dep := range deps {
+		j := c.indexOf(dep)
+		if j < 0 {
+			return fmt.Errorf("bundle %s not added", bundleDesc(dep))
+		}
+		if i == j || c.reachable(j, i) {
+			return fmt.Errorf("bundle %s depending on %s makes a cycle", bundleDesc(bundle), bundleDesc(dep))
+		}
+		c.states[i].deps = append(c.states[i].deps, j)
+	}
+	return nil
+}
+
+func (c *Container) indexOf(b Bundle) int {
+	for i, bundle := range c.bundles {
+		if bundle == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// reachable return whether the i-th bundle depends on the j-th, directly or not.
+func (c *Container) reachable(i, j int) bool {
+	for _, dep := range c.states[i].deps {
+		if dep == j || c.reachable(dep, j) {
+			return true
+		}
+	}
+	return false
+}
+
+// Supervise set the policy of bundle on failure.
+// Default: IgnoreFailure, or the one set by SetDefaultPolicy
+func (c *Container) Supervise(bundle Bundle, policy FailurePolicy) error {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	i := c.indexOf(bundle)
+	if i < 0 {
+		return fmt.Errorf("bundle %s not added", bundleDesc(bundle))
+	}
+	c.states[i].policy = &policy
+	return nil
+}
+
+// SetDefaultPolicy set the policy of the bundles not supervised.
+func (c *Container) SetDefaultPolicy(policy FailurePolicy) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.defaultPolicy = policy
+}
+
+func (c *Container) policyOf(i int) FailurePolicy {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	if p := c.states[i].policy; p != nil {
+		return *p
+	}
+	return c.defaultPolicy
+}
+
+// Failed return a channel closed once a bundle failure shuts down all, by
+// ShutdownOnFailure or RestartOnFailure running out of restarts.
+func (c *Container) Failed() <-chan struct{} {
+	return c.failed
+}
+
+// Failure return the bundle failure shutting down all, nil if none.
+func (c *Container) Failure() error {
+	select {
+	case <-c.failed:
+		return c.failure
+	default:
+		return nil
+	}
+}
+
+func (c *Container) fail(err error) {
+	c.failOnce.Do(func() {
+		c.failure = err
+		close(c.failed)
+	})
+}
+
+// BundlesReady return a channel closed once all bundles started by StartAll
+// are ready, or never will be, eg. failed or skipped.
+func (c *Container) BundlesReady() <-chan struct{} {
+	return c.allSettled
+}
+
+// BundleStatuses return the states of the bundles in the order added.
+func (c *Container) BundleStatuses() []BundleStatus {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	statuses := make([]BundleStatus, 0, len(c.bundles))
+	for i, b := range c.bundles {
+		st := c.states[i]
+		status := BundleStatus{
+			Type:     b.Type(),
+			Name:     b.Name(),
+			State:    st.state,
+			Restarts: st.restarts,
+		}
+		if st.policy != nil {
+			status.Policy = st.policy.String()
+		} else {
+			status.Policy = c.defaultPolicy.String()
+		}
+		if st.lastErr != nil {
+			status.LastError = st.lastErr.Error()
+		}
+		for _, dep := range st.deps {
+			status.DependsOn = append(status.DependsOn, bundleDesc(c.bundles[dep]))
+		}
+		if !st.startedAt.IsZero() {
+			startedAt := st.startedAt
+			status.StartedAt = &startedAt
+			end := st.endedAt
+			if end.IsZero() {
+				end = time.Now()
+			}
+			status.Uptime = end.Sub(st.startedAt).Truncate(time.Second).String()
+		}
+		if st.err != nil {
+			status.Error = st.err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
 }
 
+// setState move the i-th bundle to state if allowed from the current one, a
+// bundle returned from Run while stopping is stopped. It returns whether the
+// state is moved.
+func (c *Container) setState(i int, state string, err error) bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	st := c.states[i]
+	var from []string
+	switch state {
+	case BundleStarting:
+		from = []string{BundleIdle, BundleRestarting}
+	case BundleSkipped:
+		from = []string{BundleIdle}
+	case BundleRunning:
+		from = []string{BundleStarting}
+	case BundleRestarting:
+		from = []string{BundleStarting, BundleRunning}
+	case BundleStopping:
+		from = []string{BundleStarting, BundleRunning, BundleRestarting}
+	case BundleFinished, BundleFailed:
+		from = []string{BundleStarting, BundleRunning, BundleStopping}
+		if st.state == BundleStopping {
+			state, err = BundleStopped, nil
+		}
+	case BundleStopped:
+		from = []string{BundleStopping}
+	}
+	if !utils.ContainStr(from, st.state) {
+		return false
+	}
+
+	switch state {
+	case BundleStarting:
+		st.startedAt = time.Now()
+		st.endedAt = time.Time{}
+	case BundleRestarting:
+		st.restarts++
+	case BundleFinished, BundleFailed, BundleStopped:
+		st.endedAt = time.Now()
+	}
+	if err != nil {
+		st.lastErr = err
+	}
+	st.state = state
+	st.err = err
+	return true
+}
+
+// settle mark the i-th bundle as ready or never will be, the dependents
+// waiting for it proceed.
+func (c *Container) settle(i int, ready bool) {
+	st := c.states[i]
+	st.settleOne.Do(func() {
+		c.stateMu.Lock()
+		st.ready = ready
+		c.stateMu.Unlock()
+		close(st.settled)
+	})
+}
+
+func (c *Container) isState(i int, state string) bool {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.states[i].state == state
+}
+
+// waitDeps wait the deps of the i-th bundle ready, an error if any of them
+// never will be.
+func (c *Container) waitDeps(ctx context.Context, i int) error {
+	for _, dep := range c.states[i].deps {
+		st := c.states[dep]
+		select {
+		case <-st.settled:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		c.stateMu.RLock()
+		ready := st.ready
+		c.stateMu.RUnlock()
+		if !ready {
+			return fmt.Errorf("dependency %s not ready", bundleDesc(c.bundles[dep]))
+		}
+	}
+	return nil
+}
+
+// StartAll run the bundles in background, each after its dependencies are
+// ready. The context is done once all bundles returned from Run.
 func (c *Container) StartAll(ctx context.Context) context.Context {
 	ctx, cancel := context.WithCancel(ctx)
 
-	for _, b := range c.bundles {
-		bundle := b
-		log.Info(ctx, "Start bundle:", bundleDesc(bundle))
+	for i, b := range c.bundles {
+		i, bundle := i, b
 		c.bundleWg.Add(1)
 		go func() {
 			defer c.bundleWg.Done()
-			if err := bundle.Run(ctx); err != nil {
-				log.Errorf("Run bundle:%s failed error: %s", bundleDesc(bundle), err.Error())
+			defer c.settle(i, false)
+
+			if err := c.waitDeps(ctx, i); err != nil {
+				c.setState(i, BundleSkipped, err)
+				log.ErrorContextf(ctx, "Skip bundle:%s error: %v", bundleDesc(bundle), err)
+				return
+			}
+
+			if !c.setState(i, BundleStarting, nil) {
+				// stopped before started.
+				return
 			}
+			c.supervise(ctx, i)
 		}()
-		log.Info(ctx, "Bundle started:", bundleDesc(bundle))
 	}
 
+	go func() {
+		for _, st := range c.states {
+			<-st.settled
+		}
+		close(c.allSettled)
+	}()
+
 	go func() {
 		c.bundleWg.Wait()
 		cancel()
@@ -56,27 +392,126 @@ func (c *Container) StartAll(ctx context.Context) context.Context {
 	return ctx
 }
 
+// supervise run the i-th bundle, and handle the failures by its policy.
+func (c *Container) supervise(ctx context.Context, i int) {
+	bundle := c.bundles[i]
+	policy := c.policyOf(i)
+
+	for {
+		log.InfoContext(ctx, "Start bundle:", bundleDesc(bundle))
+		returned := make(chan struct{})
+		go c.watchReady(ctx, i, returned)
+		err := bundle.Run(ctx)
+		close(returned)
+
+		if err == nil {
+			c.setState(i, BundleFinished, nil)
+			// a bundle finished without error counts as ready, eg. a migration.
+			c.settle(i, true)
+			return
+		}
+		if c.isState(i, BundleStopping) {
+			c.setState(i, BundleFailed, err)
+			return
+		}
+		log.ErrorContextf(ctx, "Run bundle:%s failed error: %s", bundleDesc(bundle), err.Error())
+
+		c.stateMu.RLock()
+		restarts := c.states[i].restarts
+		c.stateMu.RUnlock()
+		if policy.kind == policyRestart && !policy.exhausted(restarts) && c.setState(i, BundleRestarting, err) {
+			delay := policy.delay(restarts + 1)
+			log.WarnContextf(ctx, "Restart bundle:%s in %s, restarts=%d", bundleDesc(bundle), delay, restarts+1)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.stopping:
+				timer.Stop()
+				return
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			if !c.setState(i, BundleStarting, nil) {
+				return
+			}
+			continue
+		}
+
+		if !c.setState(i, BundleFailed, err) || c.isState(i, BundleStopped) {
+			return
+		}
+		switch policy.kind {
+		case policyShutdown:
+			c.fail(fmt.Errorf("bundle %s failed: %w", bundleDesc(bundle), err))
+		case policyRestart:
+			c.fail(fmt.Errorf("bundle %s failed after %d restarts: %w", bundleDesc(bundle), policy.maxRestarts, err))
+		}
+		return
+	}
+}
+
+// watchReady mark the i-th bundle ready once its Ready channel is closed,
+// or right away if it's not a Readier.
+func (c *Container) watchReady(ctx context.Context, i int, returned <-chan struct{}) {
+	bundle := c.bundles[i]
+	if r, ok := bundle.(Readier); ok {
+		select {
+		case <-r.Ready():
+		case <-returned:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+	c.setState(i, BundleRunning, nil)
+	c.settle(i, true)
+	log.InfoContext(ctx, "Bundle started:", bundleDesc(bundle))
+}
+
+// StopAll stop the bundles, each after its dependents are stopped. The
+// context is done once all bundles stopped.
 func (c *Container) StopAll(ctx context.Context) context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
+	c.stopOnce.Do(func() {
+		close(c.stopping)
+	})
 
 	var eg utils.ErrorGroup
-	for _, b := range c.bundles {
-		bundle := b
-		log.Info(ctx, "Stop bundle:", bundleDesc(bundle))
+	for i, b := range c.bundles {
+		i, bundle := i, b
 		eg.Go(func() error {
+			defer close(c.states[i].stopped)
+
+			for j, st := range c.states {
+				for _, dep := range st.deps {
+					if dep == i {
+						<-c.states[j].stopped
+					}
+				}
+			}
+
+			if c.setState(i, BundleSkipped, errStoppedBeforeStart) || c.isState(i, BundleSkipped) {
+				// never started.
+				return nil
+			}
+
+			log.InfoContext(ctx, "Stop bundle:", bundleDesc(bundle))
+			c.setState(i, BundleStopping, nil)
 			stopCtx := bundle.Stop()
 			<-stopCtx.Done()
-			log.Info(ctx, "Bundle stopped:", bundleDesc(bundle))
+			c.setState(i, BundleStopped, nil)
+			log.InfoContext(ctx, "Bundle stopped:", bundleDesc(bundle))
 			return nil
 		})
 	}
 
 	go func() {
 		if err := eg.Wait(); err != nil {
-			log.Error(ctx, "Stop bundel error", err)
+			log.ErrorContext(ctx, "Stop bundel error: ", err)
 		}
 		cancel()
-		log.Info(ctx, "All bundle stopped")
+		log.InfoContext(ctx, "All bundle stopped")
 	}()
 
 	return ctx
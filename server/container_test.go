@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testEvents record the events of the bundles in order.
+type testEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (e *testEvents) add(event string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+}
+
+// index return the position of event, -1 if not happened.
+func (e *testEvents) index(event string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, ev := range e.events {
+		if ev == event {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *testEvents) String() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return strings.Join(e.events, ", ")
+}
+
+// testBundle is ready readyAfter its Run called, and runs until stopped.
+type testBundle struct {
+	name       string
+	events     *testEvents
+	readyAfter time.Duration
+
+	ready    chan struct{}
+	stop     chan struct{}
+	returned chan struct{}
+	stopOnce sync.Once
+}
+
+func newTestBundle(name string, events *testEvents, readyAfter time.Duration) *testBundle {
+	return &testBundle{
+		name:       name,
+		events:     events,
+		readyAfter: readyAfter,
+		ready:      make(chan struct{}),
+		stop:       make(chan struct{}),
+		returned:   make(chan struct{}),
+	}
+}
+
+func (b *testBundle) Type() string { return "test" }
+
+func (b *testBundle) Name() string { return b.name }
+
+func (b *testBundle) Ready() <-chan struct{} { return b.ready }
+
+func (b *testBundle) Run(ctx context.Context) error {
+	defer close(b.returned)
+	b.events.add("run " + b.name)
+	time.AfterFunc(b.readyAfter, func() {
+		b.events.add("ready " + b.name)
+		close(b.ready)
+	})
+	<-b.stop
+	return nil
+}
+
+func (b *testBundle) Stop() context.Context {
+	b.stopOnce.Do(func() {
+		b.events.add("stop " + b.name)
+		close(b.stop)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-b.returned
+		b.events.add("stopped " + b.name)
+		cancel()
+	}()
+	return ctx
+}
+
+func TestContainerDependencyOrder(t *testing.T) {
+	events := &testEvents{}
+	db := newTestBundle("db", events, 20*time.Millisecond)
+	cache := newTestBundle("cache", events, 10*time.Millisecond)
+	api := newTestBundle("api", events, 0)
+	other := newTestBundle("other", events, 0)
+
+	c := New()
+	// added in the reverse order of the dependencies.
+	c.AddBundle(api, cache, db, other)
+	if err := c.DependsOn(api, cache); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DependsOn(cache, db); err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx := c.StartAll(context.Background())
+	select {
+	case <-c.BundlesReady():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("bundles not ready: %s", events)
+	}
+	stopCtx := c.StopAll(context.Background())
+	for _, ctx := range []context.Context{stopCtx, runCtx} {
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("bundles not stopped: %s", events)
+		}
+	}
+
+	// each pair is ordered, the first happens before the second.
+	for _, pair := range [][2]string{
+		{"ready db", "run cache"},
+		{"ready cache", "run api"},
+		{"stopped api", "stop cache"},
+		{"stopped cache", "stop db"},
+	} {
+		i, j := events.index(pair[0]), events.index(pair[1])
+		if i < 0 || j < 0 || i > j {
+			t.Errorf("%q not before %q: %s", pair[0], pair[1], events)
+		}
+	}
+	for _, st := range c.BundleStatuses() {
+		if st.State != BundleStopped {
+			t.Errorf("bundle %s state = %s, want %s", st.Name, st.State, BundleStopped)
+		}
+	}
+}
+
+func TestContainerDependsOnErrors(t *testing.T) {
+	events := &testEvents{}
+	x := newTestBundle("x", events, 0)
+	y := newTestBundle("y", events, 0)
+	z := newTestBundle("z", events, 0)
+	missing := newTestBundle("missing", events, 0)
+
+	c := New()
+	c.AddBundle(x, y, z)
+	if err := c.DependsOn(x, y); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DependsOn(y, z); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		bundle     Bundle
+		deps       []Bundle
+		wantSubstr string
+	}{
+		{"self", x, []Bundle{x}, "makes a cycle"},
+		{"direct cycle", y, []Bundle{x}, "makes a cycle"},
+		{"indirect cycle", z, []Bundle{x}, "makes a cycle"},
+		{"bundle not added", missing, []Bundle{x}, "not added"},
+		{"dependency not added", x, []Bundle{missing}, "not added"},
+	}
+	for _, tt := range tests {
+		err := c.DependsOn(tt.bundle, tt.deps...)
+		if err == nil || !strings.Contains(err.Error(), tt.wantSubstr) {
+			t.Errorf("%s: DependsOn error = %v, want %q", tt.name, err, tt.wantSubstr)
+		}
+	}
+}
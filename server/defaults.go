@@ -1,15 +1,31 @@
 package server
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
 
 type defaults struct {
 	// name
 	appName string
+
+	// log
+	logLevel  log.Level
+	logFormat string
+
+	heartbeatInterval time.Duration
+	shutdownTimeout   time.Duration
 }
 
 func getDefaults() defaults {
 	d := defaults{
-		appName: "name",
+		appName:           "name",
+		logLevel:          log.InfoLevel,
+		logFormat:         log.FormatText,
+		heartbeatInterval: 10 * time.Second,
+		shutdownTimeout:   30 * time.Second,
 	}
 
 	return d
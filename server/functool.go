@@ -2,31 +2,84 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/YLeseclaireurs/icafe/utils"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
 )
 
+// RunUntilError run fns in order, and stop at the first error.
 func RunUntilError(ctx context.Context, fns []func(ctx context.Context) error) error {
 	for _, fn := range fns {
-		if err := fn(ctx); err != nil {
+		if err := safeCall(ctx, fn); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// RunAll run fns in parallel, and return all their errors joined. A panic is
+// logged with the stack and returned as an error.
 func RunAll(ctx context.Context, fns []func(ctx context.Context) error) error {
-	var eg utils.ErrorGroup
-	for _, fn := range fns {
-		f := fn
-		eg.Go(func() error {
-			defer func() {
-				if r := recover(); r != nil {
-					fmt.Println("recovered", r)
-				}
-			}()
-			return f(ctx)
-		})
+	var wg sync.WaitGroup
+	errs := make([]error, len(fns))
+	for i, fn := range fns {
+		i, f := i, fn
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = safeCall(ctx, f)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func safeCall(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.ErrorContextf(ctx, "Panic in %s: %v\n%s", funcName(fn), r, debug.Stack())
+			err = fmt.Errorf("%s panic: %v", funcName(fn), r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// withTimeout return fn limited by timeout, it returns once timeout or the
+// parent context is done even if fn is not, whose context is cancelled. 0 is
+// no limit.
+func withTimeout(fn func(ctx context.Context) error, timeout time.Duration) func(ctx context.Context) error {
+	if timeout <= 0 {
+		return fn
+	}
+	return func(parent context.Context) error {
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- safeCall(ctx, fn)
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			if err := parent.Err(); err != nil {
+				return fmt.Errorf("%s canceled: %w", funcName(fn), err)
+			}
+			return fmt.Errorf("%s timeout after %s", funcName(fn), timeout)
+		}
+	}
+}
+
+func funcName(fn interface{}) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		return f.Name()
 	}
-	return eg.Wait()
+	return "func"
 }
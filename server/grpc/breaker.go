@@ -0,0 +1,46 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/YLeseclaireurs/icafe/breaker"
+)
+
+// WithCircuitBreaker protect the dialed target with a circuit breaker, a call
+// to the target whose breaker is open fails with breaker.ErrCircuitOpen.
+//
+// Only the errors meaning the target is unhealthy are counted as failures
+// unless breaker.IsFailure is given.
+func WithCircuitBreaker(opts ...breaker.Option) DialOption {
+	group := breaker.NewGroup(append([]breaker.Option{breaker.IsFailure(isBreakerFailure)}, opts...)...)
+	return grpc.WithChainUnaryInterceptor(UnaryClientCircuitBreaker(group))
+}
+
+// UnaryClientCircuitBreaker is an interceptor checking the breaker of the target before each call.
+func UnaryClientCircuitBreaker(group *breaker.Group) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *ClientConn, invoker UnaryInvoker, opts ...CallOption) error {
+		b := group.Get(cc.Target())
+		if err := b.Allow(); err != nil {
+			return err
+		}
+
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.Done(err, time.Since(start))
+		return err
+	}
+}
+
+func isBreakerFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted,
+		codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	}
+	return false
+}
@@ -6,6 +6,9 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/YLeseclaireurs/icafe/tracing"
 )
 
 type ClientConn = grpc.ClientConn
@@ -21,7 +24,7 @@ func DialContext(ctx context.Context, target string, opts ...DialOption) (conn *
 	opts = append(defaultOpts, opts...)
 
 	overwriteOpts := []grpc.DialOption{
-		grpc.WithChainUnaryInterceptor(withClientTelemetry),
+		grpc.WithChainUnaryInterceptor(withClientTelemetry, withClientMetrics),
 		grpc.WithInsecure(),
 		grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy":"round_robin"}`),
 	}
@@ -31,5 +34,15 @@ func DialContext(ctx context.Context, target string, opts ...DialOption) (conn *
 }
 
 func withClientTelemetry(ctx context.Context, method string, req, reply interface{}, cc *ClientConn, invoker UnaryInvoker, opts ...CallOption) error {
-	return nil
+	ctx, span := tracing.StartSpan(ctx, method, tracing.KindClient)
+	span.SetAttribute("peer", cc.Target())
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	tracing.Inject(span.SpanContext, metadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	span.Finish(err)
+	return err
 }
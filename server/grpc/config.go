@@ -0,0 +1,40 @@
+package grpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/tomlconfig"
+)
+
+// bundleSection is the config section of a GRPCBundle, eg.
+//
+//	[grpc.admin]
+//	listen = ":9999"
+//	drain_delay = "5s"
+type bundleSection struct {
+	Listen       string        `toml:"listen" validate:"hostport"`
+	DrainDelay   time.Duration `toml:"drain_delay"`
+	DrainTimeout time.Duration `toml:"drain_timeout"`
+}
+
+// NewGRPCBundleFromConfig create a GRPCBundle from the config section, the
+// options override the section.
+func NewGRPCBundleFromConfig(name string, conf *tomlconfig.Config, opts ...GRPCOption) (*GRPCBundle, error) {
+	var s bundleSection
+	if err := conf.Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode gRPC bundle [%s] config error %s", name, err)
+	}
+
+	var sectionOpts []GRPCOption
+	if s.Listen != "" {
+		sectionOpts = append(sectionOpts, GRPCListen(s.Listen))
+	}
+	if s.DrainDelay > 0 {
+		sectionOpts = append(sectionOpts, GRPCDrainDelay(s.DrainDelay))
+	}
+	if s.DrainTimeout > 0 {
+		sectionOpts = append(sectionOpts, GRPCDrainTimeout(s.DrainTimeout))
+	}
+	return NewGRPCBundle(name, append(sectionOpts, opts...)...), nil
+}
@@ -3,21 +3,37 @@ package grpc
 import (
 	"context"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/YLeseclaireurs/icafe/log"
 )
 
 type GRPCBundle struct {
 	name       string
 	Server     *Server
 	listenAddr string
+
+	health       *health.Server
+	ready        chan struct{}
+	readyOnce    sync.Once
+	drainDelay   time.Duration
+	drainTimeout time.Duration
 }
 
 func NewGRPCBundle(name string, opts ...GRPCOption) *GRPCBundle {
 	defaults := getDefaults()
 	s := &GRPCBundle{
-		name:       name,
-		listenAddr: defaults.listenAddr,
+		name:         name,
+		listenAddr:   defaults.listenAddr,
+		drainDelay:   defaults.drainDelay,
+		drainTimeout: defaults.drainTimeout,
+		ready:        make(chan struct{}),
 	}
 
 	for _, opt := range opts {
@@ -25,6 +41,8 @@ func NewGRPCBundle(name string, opts ...GRPCOption) *GRPCBundle {
 	}
 
 	s.Server = NewServer()
+	s.health = health.NewServer()
+	healthpb.RegisterHealthServer(s.Server, s.health)
 
 	return s
 }
@@ -37,22 +55,58 @@ func (s *GRPCBundle) Name() string {
 	return s.name
 }
 
+func (s *GRPCBundle) ListenAddr() string {
+	return s.listenAddr
+}
+
+// Ready is closed once the bundle is listening.
+func (s *GRPCBundle) Ready() <-chan struct{} {
+	return s.ready
+}
+
 func (s *GRPCBundle) Run(ctx context.Context) error {
 	addr, err := net.Listen("tcp", s.listenAddr)
 	if err != nil {
 		return errors.Wrap(err, "listen failed")
 	}
-	return s.Server.Serve(addr)
+	s.readyOnce.Do(func() {
+		close(s.ready)
+	})
+	err = s.Server.Serve(addr)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
 }
 
+// Stop report NOT_SERVING by the health service first, wait the drain delay
+// for the clients to notice, then wait the in-flight calls until the drain
+// timeout, after which they are aborted. The context is done once drained.
 func (s *GRPCBundle) Stop() context.Context {
-	ctx2, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx, cancel := context.WithCancel(context.Background())
 
 	go func() {
-		s.Server.Stop()
-		ctx2.Done()
+		defer cancel()
+
+		s.health.Shutdown()
+		time.Sleep(s.drainDelay)
+
+		stopped := make(chan struct{})
+		go func() {
+			s.Server.GracefulStop()
+			close(stopped)
+		}()
+
+		timer := time.NewTimer(s.drainTimeout)
+		defer timer.Stop()
+		select {
+		case <-stopped:
+		case <-timer.C:
+			log.Warnf("Drain gRPC[%s] timeout, force stopping", s.name)
+			s.Server.Stop()
+			<-stopped
+		}
 	}()
 
-	return ctx2
+	return ctx
 }
@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/YLeseclaireurs/icafe/metrics"
+)
+
+var (
+	serverHandlingSeconds = metrics.NewHistogram(
+		"grpc_server_handling_seconds",
+		"Latency of gRPC requests handled by server.",
+		nil, "service", "method", "code",
+	)
+	clientHandlingSeconds = metrics.NewHistogram(
+		"grpc_client_handling_seconds",
+		"Latency of gRPC calls made by client.",
+		nil, "service", "method", "code",
+	)
+)
+
+func withServerMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	service, method := splitFullMethod(info.FullMethod)
+	serverHandlingSeconds.ObserveDuration(time.Since(start), service, method, status.Code(err).String())
+	return resp, err
+}
+
+func withClientMetrics(ctx context.Context, method string, req, reply interface{}, cc *ClientConn, invoker UnaryInvoker, opts ...CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	service, name := splitFullMethod(method)
+	clientHandlingSeconds.ObserveDuration(time.Since(start), service, name, status.Code(err).String())
+	return err
+}
+
+// splitFullMethod split "/package.service/method".
+func splitFullMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.Index(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
@@ -1,6 +1,6 @@
 package grpc
 
-
+import "time"
 
 type GRPCOption func(bundle *GRPCBundle)
 
@@ -9,3 +9,21 @@ func GRPCListen(listenAddr string) GRPCOption {
 		s.listenAddr = listenAddr
 	}
 }
+
+// GRPCDrainDelay set how long to wait after reporting NOT_SERVING by the
+// health service before shutting down, for the clients to stop calling.
+// Default: 0
+func GRPCDrainDelay(d time.Duration) GRPCOption {
+	return func(s *GRPCBundle) {
+		s.drainDelay = d
+	}
+}
+
+// GRPCDrainTimeout set how long the in-flight calls are waited on shutdown,
+// after which they are aborted.
+// Default: 20s
+func GRPCDrainTimeout(d time.Duration) GRPCOption {
+	return func(s *GRPCBundle) {
+		s.drainTimeout = d
+	}
+}
@@ -19,7 +19,7 @@ func NewServer(opts ...ServerOption) *Server {
 			Time:                  time.Second,
 			Timeout:               time.Millisecond * 100,
 		}),
-		//grpc.ChainUnaryInterceptor(tgrpc.WithServerTelemetry),
+		grpc.ChainUnaryInterceptor(withServerTelemetry, withServerMetrics),
 	}
 	opts = append(opts, overwriteOpts...)
 
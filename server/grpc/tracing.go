@@ -0,0 +1,42 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/YLeseclaireurs/icafe/tracing"
+)
+
+// metadataCarrier adapt gRPC metadata to tracing.Carrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// withServerTelemetry continue the trace from the incoming metadata, and
+// carry the server span in the context.
+func withServerTelemetry(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	parent, _ := tracing.Extract(metadataCarrier(md))
+
+	ctx, span := tracing.StartSpanWithParent(ctx, parent, info.FullMethod, tracing.KindServer)
+	if p, ok := peer.FromContext(ctx); ok {
+		span.SetAttribute("peer", p.Addr.String())
+	}
+
+	resp, err := handler(ctx, req)
+	span.Finish(err)
+	return resp, err
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// HookPolicy decide what happens when a lifecycle hook failed.
+type HookPolicy int
+
+const (
+	// FailFast abort the startup if a start hook failed, eg. a migration in
+	// BeforeStart, and Run returns the errors of all hooks.
+	FailFast HookPolicy = iota
+	// ContinueOnError log the errors and go on.
+	ContinueOnError
+)
+
+type hook struct {
+	fn      func(ctx context.Context) error
+	timeout time.Duration
+}
+
+type HookOption func(*hook)
+
+// HookTimeout limit the time the hook runs, its context is cancelled after
+// timeout and it counts as failed.
+// Default: no limit
+func HookTimeout(d time.Duration) HookOption {
+	return func(h *hook) {
+		h.timeout = d
+	}
+}
+
+func newHook(fn func(ctx context.Context) error, opts []HookOption) hook {
+	h := hook{fn: fn}
+	for _, o := range opts {
+		o(&h)
+	}
+	return h
+}
+
+// runHooks run the hooks in order until one failed, or all of them in
+// parallel.
+func runHooks(ctx context.Context, hooks []hook, parallel bool) error {
+	fns := make([]func(ctx context.Context) error, 0, len(hooks))
+	for _, h := range hooks {
+		fns = append(fns, withTimeout(h.fn, h.timeout))
+	}
+	if parallel {
+		return RunAll(ctx, fns)
+	}
+	return RunUntilError(ctx, fns)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errHook = errors.New("hook failed")
+
+func blockHook(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestWithTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      func(ctx context.Context) error
+		timeout time.Duration
+		// parent is done after the delay, 0 never.
+		cancelAfter, deadlineAfter time.Duration
+		wantSubstr                 string
+		wantErr                    error
+	}{
+		{"done in time", func(context.Context) error { return nil }, time.Second, 0, 0, "", nil},
+		{"failed in time", func(context.Context) error { return errHook }, time.Second, 0, 0, "hook failed", errHook},
+		{"no limit", func(context.Context) error { return errHook }, 0, 0, 0, "hook failed", errHook},
+		{"timeout", blockHook, 10 * time.Millisecond, 0, 0, "timeout after 10ms", nil},
+		{"parent canceled", blockHook, time.Second, 10 * time.Millisecond, 0, "canceled", context.Canceled},
+		{"parent deadline", blockHook, time.Second, 0, 10 * time.Millisecond, "canceled", context.DeadlineExceeded},
+		{"panic", func(context.Context) error { panic("boom") }, time.Second, 0, 0, "panic: boom", nil},
+	}
+	for _, tt := range tests {
+		parent, cancel := context.WithCancel(context.Background())
+		if tt.cancelAfter > 0 {
+			time.AfterFunc(tt.cancelAfter, cancel)
+		}
+		if tt.deadlineAfter > 0 {
+			parent, cancel = context.WithTimeout(parent, tt.deadlineAfter)
+		}
+
+		start := time.Now()
+		err := withTimeout(tt.fn, tt.timeout)(parent)
+		cancel()
+		if elapsed := time.Since(start); elapsed >= time.Second {
+			t.Errorf("%s: returned after %s", tt.name, elapsed)
+		}
+		if tt.wantSubstr == "" {
+			if err != nil {
+				t.Errorf("%s: error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantSubstr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantSubstr)
+		}
+		if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWithTimeoutCancelsHook(t *testing.T) {
+	hookErr := make(chan error, 1)
+	err := withTimeout(func(ctx context.Context) error {
+		<-ctx.Done()
+		hookErr <- ctx.Err()
+		return nil
+	}, 10*time.Millisecond)(context.Background())
+	if err == nil {
+		t.Fatal("error = nil, want timeout")
+	}
+	select {
+	case err := <-hookErr:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("hook ctx error = %v, want deadline exceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("the hook ctx is not cancelled")
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		parallel bool
+		// the result of each hook, nil succeeds.
+		results  []error
+		wantRuns int32
+		wantErrs int
+	}{
+		{"all ok", false, []error{nil, nil}, 2, 0},
+		{"stop at the first error", false, []error{nil, errHook, nil}, 2, 1},
+		{"parallel runs all", true, []error{errHook, nil, errHook}, 3, 2},
+	}
+	for _, tt := range tests {
+		var runs atomic.Int32
+		var hooks []hook
+		for _, result := range tt.results {
+			result := result
+			hooks = append(hooks, newHook(func(context.Context) error {
+				runs.Add(1)
+				return result
+			}, []HookOption{HookTimeout(time.Second)}))
+		}
+
+		err := runHooks(context.Background(), hooks, tt.parallel)
+		if got := runs.Load(); got != tt.wantRuns {
+			t.Errorf("%s: %d hooks run, want %d", tt.name, got, tt.wantRuns)
+		}
+		if got := strings.Count(errString(err), errHook.Error()); got != tt.wantErrs {
+			t.Errorf("%s: error = %v, want %d errors", tt.name, err, tt.wantErrs)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestApplicationHookPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy HookPolicy
+		want   string
+	}{
+		{"fail fast", FailFast, "before start hook: "},
+		{"continue on error", ContinueOnError, ""},
+	}
+	for _, tt := range tests {
+		app := NewApplication(Name("hook-test"), HookErrors(tt.policy),
+			BeforeStart(func(context.Context) error { return errHook }))
+		err := app.runBeforeStart()
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s: error = %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) || !errors.Is(err, errHook) {
+			t.Errorf("%s: error = %v, want %q wrapping the hook error", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
+	"github.com/YLeseclaireurs/icafe/metrics"
+	"github.com/YLeseclaireurs/icafe/utils"
+)
+
+var jobRunSeconds = metrics.NewHistogram(
+	"job_run_seconds",
+	"Latency of the job runs.",
+	nil, "bundle", "job", "status",
+)
+
+// Func is the work of a job, ctx is cancelled on timeout or when the bundle
+// stopped.
+type Func func(ctx context.Context) error
+
+type job struct {
+	name     string
+	schedule Schedule
+	fn       Func
+
+	jitter  time.Duration
+	timeout time.Duration
+	lock    *redisLock
+
+	// running keep the runs from overlapping.
+	running atomic.Bool
+}
+
+// JobBundle run the jobs on their schedules, eg.
+//
+//	jobs := job.NewJobBundle("jobs")
+//	if err := jobs.Every("warm-cache", time.Minute, warmCache, job.Jitter(5*time.Second)); err != nil {
+//		return err
+//	}
+//	if err := jobs.Cron("reconcile", "30 2 * * *", reconcile, job.Timeout(time.Hour)); err != nil {
+//		return err
+//	}
+//	app.AddBundle(jobs)
+//
+// A run is skipped if the last run of the job is not finished. The runs are
+// cancelled once the bundle stopped, and the panics are recovered.
+type JobBundle struct {
+	name string
+	jobs []*job
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	stop    chan struct{}
+	runs    sync.WaitGroup
+	done    chan struct{}
+}
+
+func NewJobBundle(name string) *JobBundle {
+	return &JobBundle{
+		name: name,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+func (b *JobBundle) Type() string {
+	return "job"
+}
+
+func (b *JobBundle) Name() string {
+	return b.name
+}
+
+// Cron add a job run by the cron expression, see ParseCron.
+func (b *JobBundle) Cron(name, spec string, fn Func, opts ...JobOption) error {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return fmt.Errorf("job %s[%s]: %v", b.name, name, err)
+	}
+	return b.Schedule(name, schedule, fn, opts...)
+}
+
+// Every add a job run at a fixed interval, which must be positive.
+func (b *JobBundle) Every(name string, interval time.Duration, fn Func, opts ...JobOption) error {
+	schedule, err := Every(interval)
+	if err != nil {
+		return fmt.Errorf("job %s[%s]: %v", b.name, name, err)
+	}
+	return b.Schedule(name, schedule, fn, opts...)
+}
+
+// Schedule add a job run by the schedule, the jobs must be added before Run.
+func (b *JobBundle) Schedule(name string, schedule Schedule, fn Func, opts ...JobOption) error {
+	j := &job{name: name, schedule: schedule, fn: fn}
+	for _, o := range opts {
+		o(j)
+	}
+	switch {
+	case schedule == nil:
+		return fmt.Errorf("job %s[%s]: nil schedule", b.name, name)
+	case fn == nil:
+		return fmt.Errorf("job %s[%s]: nil func", b.name, name)
+	case j.lock != nil && j.lock.ttl <= 0:
+		return fmt.Errorf("job %s[%s]: invalid lock ttl %s: must be positive", b.name, name, j.lock.ttl)
+	}
+	if j.lock != nil {
+		j.lock.key = "job:" + b.name + ":" + name
+	}
+	b.jobs = append(b.jobs, j)
+	return nil
+}
+
+func (b *JobBundle) Run(ctx context.Context) error {
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		return nil
+	}
+	b.started = true
+	b.mu.Unlock()
+	defer close(b.done)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-b.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var schedulers sync.WaitGroup
+	for _, j := range b.jobs {
+		j := j
+		schedulers.Add(1)
+		go func() {
+			defer schedulers.Done()
+			b.schedule(ctx, j)
+		}()
+	}
+	schedulers.Wait()
+	b.runs.Wait()
+	return nil
+}
+
+// Stop cancel the running jobs, the context is done once they returned.
+func (b *JobBundle) Stop() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.stopped {
+		b.stopped = true
+		close(b.stop)
+	}
+	if !b.started {
+		cancel()
+		return ctx
+	}
+
+	go func() {
+		defer cancel()
+		<-b.done
+	}()
+	return ctx
+}
+
+// schedule wait for the times of j, and run it unless the last run is not
+// finished.
+func (b *JobBundle) schedule(ctx context.Context, j *job) {
+	next := time.Now()
+	for {
+		prev := next
+		next = j.schedule.Next(prev)
+		if next.IsZero() {
+			return
+		}
+		// a schedule not moving forward would spin the loop.
+		if !next.After(prev) {
+			log.ErrorContextf(ctx, "Stop job %s[%s]: schedule next %s not after %s", b.name, j.name, next, prev)
+			return
+		}
+		delay := time.Until(next)
+		if j.jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(j.jitter)))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// the ticks missed by a slow run are not caught up.
+		if now := time.Now(); next.Before(now) {
+			next = now
+		}
+
+		if !j.running.CompareAndSwap(false, true) {
+			log.WarnContextf(ctx, "Skip job %s[%s]: last run not finished", b.name, j.name)
+			continue
+		}
+		b.runs.Add(1)
+		go func() {
+			defer b.runs.Done()
+			defer j.running.Store(false)
+			b.run(ctx, j)
+		}()
+	}
+}
+
+// run the job once, with the lock if any.
+func (b *JobBundle) run(ctx context.Context, j *job) {
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+
+	if j.lock != nil {
+		token, err := j.lock.acquire(ctx)
+		if err != nil {
+			log.ErrorContextf(ctx, "Lock job %s[%s] error: %v", b.name, j.name, err)
+			return
+		}
+		if token == "" {
+			log.DebugContextf(ctx, "Skip job %s[%s]: locked by another instance", b.name, j.name)
+			return
+		}
+		defer func() {
+			if err := j.lock.release(context.Background(), token); err != nil {
+				log.ErrorContextf(ctx, "Unlock job %s[%s] error: %v", b.name, j.name, err)
+			}
+		}()
+	}
+
+	start := time.Now()
+	var err error
+	panicErr := utils.SafelyRun(func() {
+		err = j.fn(ctx)
+	})
+
+	status := "ok"
+	switch {
+	case panicErr != nil:
+		status = "panic"
+		log.ErrorContextf(ctx, "Job %s[%s] panic: %v", b.name, j.name, panicErr)
+	case err != nil && ctx.Err() == context.DeadlineExceeded:
+		status = "timeout"
+		log.ErrorContextf(ctx, "Job %s[%s] timeout after %s: %v", b.name, j.name, j.timeout, err)
+	case err != nil && ctx.Err() == context.Canceled:
+		status = "canceled"
+		log.WarnContextf(ctx, "Job %s[%s] canceled: %v", b.name, j.name, err)
+	case err != nil:
+		status = "error"
+		log.ErrorContextf(ctx, "Job %s[%s] error: %v", b.name, j.name, err)
+	}
+	jobRunSeconds.ObserveDuration(time.Since(start), b.name, j.name, status)
+}
@@ -0,0 +1,363 @@
+package job
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/redis"
+)
+
+// runBundle run b until the test ends, the returned stop stops it and waits.
+func runBundle(t *testing.T, b *JobBundle) (stop func()) {
+	t.Helper()
+	returned := make(chan error, 1)
+	go func() {
+		returned <- b.Run(context.Background())
+	}()
+	stop = func() {
+		select {
+		case <-b.Stop().Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("Stop not done")
+		}
+		select {
+		case err := <-returned:
+			if err != nil {
+				t.Errorf("Run error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run not returned")
+		}
+	}
+	t.Cleanup(func() { b.Stop() })
+	return stop
+}
+
+// waitFor poll cond until true, or fail the test.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJobBundleSchedule(t *testing.T) {
+	fn := func(context.Context) error { return nil }
+	b := NewJobBundle("jobs")
+	tests := []struct {
+		name string
+		add  func() error
+	}{
+		{"zero interval", func() error { return b.Every("j", 0, fn) }},
+		{"negative interval", func() error { return b.Every("j", -time.Second, fn) }},
+		{"invalid cron", func() error { return b.Cron("j", "* * *", fn) }},
+		{"nil schedule", func() error { return b.Schedule("j", nil, fn) }},
+		{"nil func", func() error { return b.Every("j", time.Second, nil) }},
+		{"zero lock ttl", func() error { return b.Every("j", time.Second, fn, WithLock(nil, 0)) }},
+	}
+	for _, tt := range tests {
+		if err := tt.add(); err == nil || !strings.HasPrefix(err.Error(), "job jobs[j]: ") {
+			t.Errorf("%s: error = %v, want job jobs[j] error", tt.name, err)
+		}
+	}
+	if len(b.jobs) != 0 {
+		t.Errorf("%d invalid jobs added", len(b.jobs))
+	}
+}
+
+func TestJobBundleSkipOverlapping(t *testing.T) {
+	var runs, active, maxActive atomic.Int32
+	b := NewJobBundle("jobs")
+	err := b.Every("slow", 5*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runBundle(t, b)
+
+	waitFor(t, "2 runs", func() bool { return runs.Load() >= 2 })
+	stop()
+	if got := maxActive.Load(); got != 1 {
+		t.Errorf("max concurrent runs = %d, want 1", got)
+	}
+}
+
+func TestJobBundleTimeout(t *testing.T) {
+	errs := make(chan error, 10)
+	b := NewJobBundle("jobs")
+	err := b.Every("timeout", 5*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+		select {
+		case errs <- ctx.Err():
+		default:
+		}
+		return ctx.Err()
+	}, Timeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runBundle(t, b)
+	defer stop()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("run ctx error = %v, want deadline exceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the run is not cancelled by the timeout")
+	}
+}
+
+func TestJobBundleStopCancelsRuns(t *testing.T) {
+	var started, finished atomic.Bool
+	var runErr atomic.Value
+	b := NewJobBundle("jobs")
+	err := b.Every("long", 5*time.Millisecond, func(ctx context.Context) error {
+		started.Store(true)
+		<-ctx.Done()
+		runErr.Store(ctx.Err())
+		// the run takes a while to clean up, Stop waits for it.
+		time.Sleep(20 * time.Millisecond)
+		finished.Store(true)
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runBundle(t, b)
+
+	waitFor(t, "the run started", started.Load)
+	stop()
+	if !finished.Load() {
+		t.Error("Stop done before the run returned")
+	}
+	if err, _ := runErr.Load().(error); !errors.Is(err, context.Canceled) {
+		t.Errorf("run ctx error = %v, want canceled", err)
+	}
+}
+
+func TestJobBundleStopBeforeRun(t *testing.T) {
+	b := NewJobBundle("jobs")
+	if err := b.Every("j", time.Millisecond, func(context.Context) error {
+		t.Error("run after stopped")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-b.Stop().Done():
+	case <-time.After(time.Second):
+		t.Fatal("Stop before Run not done")
+	}
+	if err := b.Run(context.Background()); err != nil {
+		t.Errorf("Run after Stop error = %v", err)
+	}
+}
+
+func TestJobBundleRecoverPanic(t *testing.T) {
+	var runs atomic.Int32
+	b := NewJobBundle("jobs")
+	err := b.Every("panic", 5*time.Millisecond, func(context.Context) error {
+		if runs.Add(1) == 1 {
+			panic("boom")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runBundle(t, b)
+	defer stop()
+
+	// the job keeps running after the panic.
+	waitFor(t, "a run after the panic", func() bool { return runs.Load() >= 2 })
+}
+
+// fakeRedis serve SET with NX and PX, and EVAL of the release script.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	values   map[string]string
+	acquired int
+	released int
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{ln: ln, values: map[string]string{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) addr() string {
+	return "redis://" + r.ln.Addr().String()
+}
+
+func (r *fakeRedis) get(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.values[key]
+	return v, ok
+}
+
+func (r *fakeRedis) set(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = value
+}
+
+func (r *fakeRedis) del(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.values, key)
+}
+
+func (r *fakeRedis) counts() (acquired, released int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.acquired, r.released
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte(r.do(args))); err != nil {
+			return
+		}
+	}
+}
+
+func (r *fakeRedis) do(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		key, value := args[1], args[2]
+		if _, ok := r.values[key]; ok {
+			return "$-1\r\n"
+		}
+		r.values[key] = value
+		r.acquired++
+		return "+OK\r\n"
+	case "EVAL":
+		key, token := args[3], args[4]
+		if r.values[key] != token {
+			return ":0\r\n"
+		}
+		delete(r.values, key)
+		r.released++
+		return ":1\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+// readCommand read a command as an array of bulk strings.
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := rd.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestJobBundleLock(t *testing.T) {
+	fake := newFakeRedis(t)
+	r := redis.NewRWRedis("jobs", fake.addr(), nil)
+	const key = "job:jobs:locked"
+	// held by another instance.
+	fake.set(key, "other")
+
+	var runs atomic.Int32
+	var lockHeld atomic.Bool
+	b := NewJobBundle("jobs")
+	err := b.Every("locked", 5*time.Millisecond, func(context.Context) error {
+		runs.Add(1)
+		if v, ok := fake.get(key); ok && v != "other" {
+			lockHeld.Store(true)
+		}
+		return nil
+	}, WithLock(r, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := runBundle(t, b)
+
+	time.Sleep(50 * time.Millisecond)
+	if n := runs.Load(); n != 0 {
+		t.Fatalf("%d runs while locked by another instance", n)
+	}
+
+	fake.del(key)
+	waitFor(t, "a run after unlocked", func() bool { return runs.Load() >= 1 })
+	stop()
+
+	if !lockHeld.Load() {
+		t.Error("the run does not hold the lock")
+	}
+	if acquired, released := fake.counts(); acquired == 0 || acquired != released {
+		t.Errorf("lock acquired %d times, released %d times", acquired, released)
+	}
+	if _, ok := fake.get(key); ok {
+		t.Error("the lock is not released after the run")
+	}
+}
@@ -0,0 +1,50 @@
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/redis"
+)
+
+// releaseScript delete the lock only if it's still held by the token.
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// redisLock is a lock on a redis key, held by whoever set the key first
+// until released or expired.
+type redisLock struct {
+	redis *redis.RWRedis
+	key   string
+	ttl   time.Duration
+}
+
+// acquire return the token holding the lock, empty if held by others.
+func (l *redisLock) acquire(ctx context.Context) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	reply, err := l.redis.Do(ctx, "SET", l.key, token, "NX", "PX", l.ttl.Milliseconds())
+	if err != nil {
+		return "", err
+	}
+	if reply == nil {
+		return "", nil
+	}
+	return token, nil
+}
+
+func (l *redisLock) release(ctx context.Context, token string) error {
+	_, err := l.redis.Do(ctx, "EVAL", releaseScript, 1, l.key, token)
+	return err
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,36 @@
+package job
+
+import (
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/redis"
+)
+
+type JobOption func(*job)
+
+// Jitter delay each run by a random duration up to d, to spread the runs of
+// the instances.
+// Default: 0
+func Jitter(d time.Duration) JobOption {
+	return func(j *job) {
+		j.jitter = d
+	}
+}
+
+// Timeout cancel the context of a run after d.
+// Default: no limit
+func Timeout(d time.Duration) JobOption {
+	return func(j *job) {
+		j.timeout = d
+	}
+}
+
+// WithLock run the job on one instance at a time by a lock on the redis key
+// "job:<bundle>:<job>", the runs not getting the lock are skipped. The lock
+// expires after ttl in case the holder died, which must be positive and
+// should be longer than the runs.
+func WithLock(r *redis.RWRedis, ttl time.Duration) JobOption {
+	return func(j *job) {
+		j.lock = &redisLock{redis: r, ttl: ttl}
+	}
+}
@@ -0,0 +1,158 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@every",
+		"@every 0s",
+		"@every -1m",
+		"@every x",
+		"@fortnightly",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) error = nil, want error", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		// 2024-01-01 is a monday.
+		{"*/5 * * * *", at(1, 1, 0, 0), at(1, 1, 0, 5)},
+		{"*/5 * * * *", at(1, 1, 0, 3).Add(30 * time.Second), at(1, 1, 0, 5)},
+		{"*/5 * * * *", at(1, 1, 23, 55), at(1, 2, 0, 0)},
+		{"30 2 * * mon-fri", at(1, 5, 3, 0), at(1, 8, 2, 30)},
+		{"0 0 1,15 * *", at(1, 1, 0, 0), at(1, 15, 0, 0)},
+		{"0 0 1 jan *", at(1, 1, 0, 0), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * MON", at(1, 1, 0, 0), at(1, 8, 0, 0)},
+		{"0 9-17/4 * * *", at(1, 1, 10, 0), at(1, 1, 13, 0)},
+		{"0 9-17/4 * * *", at(1, 1, 13, 0), at(1, 1, 17, 0)},
+
+		// "n/step" runs from n to the max.
+		{"5/20 * * * *", at(1, 1, 0, 0), at(1, 1, 0, 5)},
+		{"5/20 * * * *", at(1, 1, 0, 5), at(1, 1, 0, 25)},
+		{"5/20 * * * *", at(1, 1, 0, 45), at(1, 1, 1, 5)},
+
+		// 7 is sunday as 0.
+		{"0 0 * * 7", at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		{"0 0 * * 0", at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		{"0 0 * * sun", at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		{"0 0 * * 5-7", at(1, 1, 0, 0), at(1, 5, 0, 0)},
+
+		// the days match either the day of month or of week if both are set.
+		{"0 12 13 * fri", at(1, 1, 0, 0), at(1, 5, 12, 0)},
+		{"0 12 13 * fri", at(1, 12, 13, 0), at(1, 13, 12, 0)},
+		{"0 12 13 * *", at(1, 1, 0, 0), at(1, 13, 12, 0)},
+		{"0 12 * * fri", at(1, 1, 0, 0), at(1, 5, 12, 0)},
+		{"0 12 13 * ?", at(1, 1, 0, 0), at(1, 13, 12, 0)},
+
+		// the missing days are skipped.
+		{"0 0 29 2 *", at(3, 1, 0, 0), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", at(4, 1, 0, 0), at(5, 31, 0, 0)},
+		{"0 0 30 2 *", at(1, 1, 0, 0), time.Time{}},
+
+		{"@hourly", at(1, 1, 0, 30), at(1, 1, 1, 0)},
+		{"@daily", at(1, 1, 0, 0), at(1, 2, 0, 0)},
+		{"@weekly", at(1, 1, 0, 0), at(1, 7, 0, 0)},
+		{"@monthly", at(1, 1, 0, 0), at(2, 1, 0, 0)},
+		{"@yearly", at(1, 1, 0, 0), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", at(1, 1, 0, 0), at(1, 1, 1, 30)},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next(%s) = %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("load location: %v", err)
+	}
+	// DST starts at 2024-03-10 02:00 EST and ends at 2024-11-03 02:00 EDT.
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, loc)
+	}
+	// 01:30 EST after the clock is set back.
+	repeated := at(11, 3, 1, 30).Add(time.Hour)
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want []time.Time
+	}{
+		// the skipped wall times never match.
+		{"30 2 * * *", at(3, 10, 0, 0), []time.Time{at(3, 11, 2, 30)}},
+		{"0 * * * *", at(3, 10, 0, 30), []time.Time{at(3, 10, 1, 0), at(3, 10, 3, 0), at(3, 10, 4, 0)}},
+		{"*/30 1-3 * * *", at(3, 10, 1, 0), []time.Time{at(3, 10, 1, 30), at(3, 10, 3, 0)}},
+
+		// the repeated wall times match once.
+		{"30 1 * * *", at(11, 3, 0, 0), []time.Time{at(11, 3, 1, 30), at(11, 4, 1, 30)}},
+		{"0 * * * *", at(11, 3, 0, 30), []time.Time{at(11, 3, 1, 0), at(11, 3, 2, 0)}},
+		{"30 1 * * *", repeated.Add(-20 * time.Minute), []time.Time{repeated, at(11, 4, 1, 30)}},
+		{"30 2 * * *", at(11, 3, 0, 0), []time.Time{at(11, 3, 2, 30)}},
+	}
+	for _, tt := range tests {
+		s, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q) error = %v", tt.spec, err)
+			continue
+		}
+		from := tt.from
+		for _, want := range tt.want {
+			got := s.Next(from)
+			if !got.Equal(want) {
+				t.Errorf("ParseCron(%q).Next(%s) = %s, want %s", tt.spec, from, got, want)
+				break
+			}
+			from = got
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		if _, err := Every(d); err == nil {
+			t.Errorf("Every(%s) error = nil, want error", d)
+		}
+	}
+
+	s, err := Every(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)
+	if got, want := s.Next(from), from.Add(time.Minute); !got.Equal(want) {
+		t.Errorf("Every(1m).Next(%s) = %s, want %s", from, got, want)
+	}
+}
@@ -2,6 +2,10 @@ package server
 
 import (
 	"context"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
+	"github.com/YLeseclaireurs/icafe/tomlconfig"
 )
 
 // 必须用指针这种方式来区别未设置还是0值.
@@ -9,18 +13,45 @@ type options struct {
 	appName *string
 	ctx     context.Context
 
-	withConfig bool
+	withConfig  bool
+	watchConfig bool
+	configOpts  []tomlconfig.Option
 
 	// pprof port
 	profilerPort *int
 
+	// metrics port
+	metricsPort *int
+
+	// admin port
+	adminPort *int
+
+	sentryDSN    *string
 	includePaths []string
 
+	// log
+	logLevel  *log.Level
+	logFormat *string
+	logCaller *bool
+	logFile   *string
+	logRotate []log.RotateOption
+	// logSample create the sampler, in NewApplication.
+	logSample func() *log.Sampler
+
+	// service registration
+	registrar         Registrar
+	heartbeatInterval *time.Duration
+
+	shutdownTimeout *time.Duration
+	failurePolicy   *FailurePolicy
+
 	// lifecycle hooks
-	beforeStart []func(ctx context.Context) error
-	afterStart  []func(ctx context.Context) error
-	beforeStop  []func(ctx context.Context) error
-	afterStop   []func(ctx context.Context) error
+	beforeStart  []hook
+	afterStart   []hook
+	beforeStop   []hook
+	afterStop    []hook
+	hookPolicy   *HookPolicy
+	hookParallel bool
 }
 
 type Option func(*options)
@@ -37,44 +68,188 @@ func WithContext(ctx context.Context) Option {
 	}
 }
 
-func WithConfig() Option {
+// WithConfig load the layered config, see tomlconfig.Config, which is
+// retrieved by Application.Config.
+func WithConfig(configOpts ...tomlconfig.Option) Option {
 	return func(opts *options) {
 		opts.withConfig = true
+		opts.configOpts = configOpts
 	}
 }
 
+// WatchConfig load the config as WithConfig, and reload it once the files
+// changed or SIGHUP received, see tomlconfig.Watcher.
+func WatchConfig(configOpts ...tomlconfig.Option) Option {
+	return func(opts *options) {
+		opts.withConfig = true
+		opts.watchConfig = true
+		opts.configOpts = configOpts
+	}
+}
+
+// WithProfiler serve pprof and the metrics on the default mux.
+//
+// Deprecated: use WithAdmin, which serves them on its own mux along with
+// the health and the bundle states.
 func WithProfiler(port int) Option {
 	return func(opts *options) {
 		opts.profilerPort = &port
 	}
 }
 
+// WithMetrics expose the metrics on a dedicated port at /metrics, the metrics
+// are also exposed on the profiler port if WithProfiler is given.
+func WithMetrics(port int) Option {
+	return func(opts *options) {
+		opts.metricsPort = &port
+	}
+}
+
+// WithAdmin serve the liveness, the readiness, the bundle states, the metrics
+// and pprof on port, see BaseApplication.AdminHandler.
+func WithAdmin(port int) Option {
+	return func(opts *options) {
+		opts.adminPort = &port
+	}
+}
+
+// SentryDSN report the error log lines and the recovered panics to sentry.
+func SentryDSN(dsn string) Option {
+	return func(o *options) {
+		o.sentryDSN = &dsn
+	}
+}
+
+// SentryIncludePaths keep only the stack frames of the paths in the reported
+// events, eg. the module path of the application.
 func SentryIncludePaths(paths ...string) Option {
 	return func(o *options) {
 		o.includePaths = paths
 	}
 }
 
-func BeforeStart(fn func(ctx context.Context) error) Option {
+// LogLevel set the level of the standard logger.
+// Default: info
+func LogLevel(level log.Level) Option {
+	return func(opts *options) {
+		opts.logLevel = &level
+	}
+}
+
+// LogFormat set the log format, one of text, json and logfmt.
+// Default: text
+func LogFormat(format string) Option {
+	return func(opts *options) {
+		opts.logFormat = &format
+	}
+}
+
+// LogCaller enable reporting the file and line of the log caller.
+// Default: false
+func LogCaller(enable bool) Option {
+	return func(opts *options) {
+		opts.logCaller = &enable
+	}
+}
+
+// LogFile write the log to a rotating file instead of stderr, the queued lines
+// are drained when the application stopped.
+func LogFile(filename string, opts ...log.RotateOption) Option {
+	return func(o *options) {
+		o.logFile = &filename
+		o.logRotate = opts
+	}
+}
+
+// LogSampling log the first lines of each call site every second, then one in
+// every thereafter lines, see log.NewSampler.
+func LogSampling(first, thereafter int, opts ...log.SamplerOption) Option {
+	return func(o *options) {
+		o.logSample = func() *log.Sampler {
+			return log.NewSampler(first, thereafter, opts...)
+		}
+	}
+}
+
+// WithRegistrar register the listening bundles after started, and deregister
+// them before stopped.
+func WithRegistrar(r Registrar) Option {
+	return func(opts *options) {
+		opts.registrar = r
+	}
+}
+
+// RegistrarHeartbeat set the interval to send heartbeat to the registrar, a
+// non-positive interval keeps the default.
+// Default: 10s
+func RegistrarHeartbeat(interval time.Duration) Option {
+	return func(opts *options) {
+		if interval <= 0 {
+			return
+		}
+		opts.heartbeatInterval = &interval
+	}
+}
+
+// ShutdownTimeout set how long the bundles are waited to stop, after which
+// the application exits anyway. It should be longer than the drain timeouts
+// of the bundles, a non-positive timeout keeps the default.
+// Default: 30s
+func ShutdownTimeout(d time.Duration) Option {
+	return func(opts *options) {
+		if d <= 0 {
+			return
+		}
+		opts.shutdownTimeout = &d
+	}
+}
+
+// DefaultFailurePolicy set the policy of the bundles not supervised by
+// Container.Supervise.
+// Default: IgnoreFailure
+func DefaultFailurePolicy(policy FailurePolicy) Option {
+	return func(opts *options) {
+		opts.failurePolicy = &policy
+	}
+}
+
+// HookErrors set what happens when a lifecycle hook failed.
+// Default: FailFast
+func HookErrors(policy HookPolicy) Option {
+	return func(opts *options) {
+		opts.hookPolicy = &policy
+	}
+}
+
+// ParallelHooks run the hooks of each lifecycle phase in parallel instead of
+// in order.
+// Default: false
+func ParallelHooks() Option {
+	return func(opts *options) {
+		opts.hookParallel = true
+	}
+}
+
+func BeforeStart(fn func(ctx context.Context) error, hookOpts ...HookOption) Option {
 	return func(opts *options) {
-		opts.beforeStart = append(opts.beforeStart, fn)
+		opts.beforeStart = append(opts.beforeStart, newHook(fn, hookOpts))
 	}
 }
 
-func AfterStart(fn func(ctx context.Context) error) Option {
+func AfterStart(fn func(ctx context.Context) error, hookOpts ...HookOption) Option {
 	return func(opts *options) {
-		opts.afterStart = append(opts.afterStart, fn)
+		opts.afterStart = append(opts.afterStart, newHook(fn, hookOpts))
 	}
 }
 
-func BeforeStop(fn func(ctx context.Context) error) Option {
+func BeforeStop(fn func(ctx context.Context) error, hookOpts ...HookOption) Option {
 	return func(opts *options) {
-		opts.beforeStop = append(opts.beforeStop, fn)
+		opts.beforeStop = append(opts.beforeStop, newHook(fn, hookOpts))
 	}
 }
 
-func AfterStop(fn func(ctx context.Context) error) Option {
+func AfterStop(fn func(ctx context.Context) error, hookOpts ...HookOption) Option {
 	return func(opts *options) {
-		opts.afterStop = append(opts.afterStop, fn)
+		opts.afterStop = append(opts.afterStop, newHook(fn, hookOpts))
 	}
 }
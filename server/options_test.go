@@ -0,0 +1,33 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  func(time.Duration) Option
+		get  func(*options) *time.Duration
+	}{
+		{"ShutdownTimeout", ShutdownTimeout, func(o *options) *time.Duration { return o.shutdownTimeout }},
+		{"RegistrarHeartbeat", RegistrarHeartbeat, func(o *options) *time.Duration { return o.heartbeatInterval }},
+	}
+	for _, tt := range tests {
+		// the non-positive durations keep the default.
+		for _, d := range []time.Duration{0, -time.Second} {
+			o := &options{}
+			tt.opt(d)(o)
+			if got := tt.get(o); got != nil {
+				t.Errorf("%s(%s) = %s, want the default", tt.name, d, *got)
+			}
+		}
+
+		o := &options{}
+		tt.opt(time.Minute)(o)
+		if got := tt.get(o); got == nil || *got != time.Minute {
+			t.Errorf("%s(1m) = %v, want 1m", tt.name, got)
+		}
+	}
+}
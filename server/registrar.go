@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/YLeseclaireurs/icafe/utils"
+)
+
+// Listener is implemented by bundles serving on a network address, the
+// address is announced to the registrar once the bundle started.
+type Listener interface {
+	ListenAddr() string
+}
+
+// Instance is a listening bundle announced to the registry.
+type Instance struct {
+	// Name is the name peers discover the bundle with, the bundle name.
+	Name string
+	// Type is the bundle type, eg. tzone or gRPC.
+	Type string
+	// Address is the advertised "ip:port" of the bundle.
+	Address string
+}
+
+// Registrar announce the listening bundles to a service registry.
+type Registrar interface {
+	Register(ctx context.Context, instance *Instance) error
+	Heartbeat(ctx context.Context, instance *Instance) error
+	Deregister(ctx context.Context, instance *Instance) error
+}
+
+// MemoryRegistrar keep the instances in process, mostly for testing.
+type MemoryRegistrar struct {
+	mu        sync.RWMutex
+	instances map[string][]string
+}
+
+func NewMemoryRegistrar() *MemoryRegistrar {
+	return &MemoryRegistrar{
+		instances: map[string][]string{},
+	}
+}
+
+func (r *MemoryRegistrar) Register(ctx context.Context, instance *Instance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.instances[instance.Name] = addInstanceAddress(r.instances[instance.Name], instance.Address)
+	return nil
+}
+
+func (r *MemoryRegistrar) Heartbeat(ctx context.Context, instance *Instance) error {
+	return r.Register(ctx, instance)
+}
+
+func (r *MemoryRegistrar) Deregister(ctx context.Context, instance *Instance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.instances[instance.Name] = utils.RemoveSliceStr(r.instances[instance.Name], instance.Address)
+	return nil
+}
+
+// Addresses return the registered addresses of the named instances.
+func (r *MemoryRegistrar) Addresses(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.instances[name]...)
+}
+
+// FileRegistrar write the instances into a JSON file, which maps name to a
+// list of "ip:port" addresses and can be read by rpc.FileResolver.
+//
+// The file is rewritten atomically, but concurrent writers from different
+// processes are not coordinated, so it is meant for local development.
+type FileRegistrar struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileRegistrar(path string) *FileRegistrar {
+	return &FileRegistrar{path: path}
+}
+
+func (r *FileRegistrar) Register(ctx context.Context, instance *Instance) error {
+	return r.update(func(instances map[string][]string) {
+		instances[instance.Name] = addInstanceAddress(instances[instance.Name], instance.Address)
+	})
+}
+
+func (r *FileRegistrar) Heartbeat(ctx context.Context, instance *Instance) error {
+	return r.Register(ctx, instance)
+}
+
+func (r *FileRegistrar) Deregister(ctx context.Context, instance *Instance) error {
+	return r.update(func(instances map[string][]string) {
+		instances[instance.Name] = utils.RemoveSliceStr(instances[instance.Name], instance.Address)
+		if len(instances[instance.Name]) == 0 {
+			delete(instances, instance.Name)
+		}
+	})
+}
+
+func (r *FileRegistrar) update(fn func(map[string][]string)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	instances := map[string][]string{}
+	data, err := os.ReadFile(r.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &instances); err != nil {
+			return err
+		}
+	}
+
+	fn(instances)
+
+	data, err = json.MarshalIndent(instances, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+func addInstanceAddress(addrs []string, addr string) []string {
+	if utils.ContainStr(addrs, addr) {
+		return addrs
+	}
+	return append(addrs, addr)
+}
+
+// advertiseAddr replace the unspecified host of listen address with the
+// first non-loopback IPv4 address.
+func advertiseAddr(listenAddr string) (string, error) {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return listenAddr, nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return net.JoinHostPort(ipNet.IP.String(), port), nil
+		}
+	}
+	return net.JoinHostPort("127.0.0.1", port), nil
+}
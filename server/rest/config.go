@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/tomlconfig"
+)
+
+// bundleSection is the config section of a HTTPBundle, eg.
+//
+//	[http.api]
+//	listen = "0.0.0.0:8080"
+//	drain_delay = "5s"
+type bundleSection struct {
+	Listen       string        `toml:"listen" validate:"hostport"`
+	DrainDelay   time.Duration `toml:"drain_delay"`
+	DrainTimeout time.Duration `toml:"drain_timeout"`
+}
+
+// NewHTTPBundleFromConfig create a HTTPBundle from the config section, the
+// options override the section.
+func NewHTTPBundleFromConfig(name string, conf *tomlconfig.Config, opts ...HTTPOption) (*HTTPBundle, error) {
+	var s bundleSection
+	if err := conf.Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode http bundle [%s] config error %s", name, err)
+	}
+
+	var sectionOpts []HTTPOption
+	if s.Listen != "" {
+		sectionOpts = append(sectionOpts, HTTPListen(s.Listen))
+	}
+	if s.DrainDelay > 0 {
+		sectionOpts = append(sectionOpts, HTTPDrainDelay(s.DrainDelay))
+	}
+	if s.DrainTimeout > 0 {
+		sectionOpts = append(sectionOpts, HTTPDrainTimeout(s.DrainTimeout))
+	}
+	return NewHTTPBundle(name, append(sectionOpts, opts...)...), nil
+}
@@ -0,0 +1,21 @@
+package rest
+
+import "time"
+
+type Defaults struct {
+	Name string
+
+	listenAddr   string
+	drainDelay   time.Duration
+	drainTimeout time.Duration
+}
+
+func getDefaults() Defaults {
+	d := Defaults{
+		Name:         "name",
+		listenAddr:   "0.0.0.0:8080",
+		drainTimeout: 20 * time.Second,
+	}
+
+	return d
+}
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/YLeseclaireurs/icafe/log"
+	"github.com/YLeseclaireurs/icafe/utils"
+)
+
+// maxBodyBytes limit the request body bound by Bind.
+const maxBodyBytes = 4 << 20
+
+// Error is replied by WriteError as {"code": ..., "message": ...} with the status.
+type Error struct {
+	Status  int    `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func NewError(status int, message string) *Error {
+	return &Error{Status: status, Code: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("http %d: %s", e.Status, e.Message)
+}
+
+// Bind decode the JSON body of the request into v, the big integers in
+// interface{} values are kept as json.Number, see utils.JSONUnmarshal. The
+// error is an *Error of status 400 or 413.
+func Bind(r *http.Request, v interface{}) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, _ := mime.ParseMediaType(ct); mediaType != "application/json" {
+			return NewError(http.StatusUnsupportedMediaType, "content type not application/json")
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return NewError(http.StatusRequestEntityTooLarge, "request body too large")
+		}
+		return NewError(http.StatusBadRequest, "read request body error: "+err.Error())
+	}
+	if len(body) == 0 {
+		return NewError(http.StatusBadRequest, "empty request body")
+	}
+	if err := utils.JSONUnmarshal(body, v); err != nil {
+		return NewError(http.StatusBadRequest, "invalid json: "+err.Error())
+	}
+	return nil
+}
+
+// JSON reply v as JSON with the status.
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Errorf("Marshal response error: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// WriteError reply the error as JSON, with its status if an *Error, otherwise
+// 500 without exposing the message.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		log.ErrorContextf(r.Context(), "Handle %s %s error: %v", r.Method, r.URL.Path, err)
+		e = NewError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+	JSON(w, e.Status, e)
+}
+
+// HandlerFunc is a handler returning the error to reply by WriteError.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (h HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h(w, r); err != nil {
+		WriteError(w, r, err)
+	}
+}
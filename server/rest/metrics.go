@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/metrics"
+	"github.com/YLeseclaireurs/icafe/tracing"
+)
+
+var serverHandlingSeconds = metrics.NewHistogram(
+	"http_server_handling_seconds",
+	"Latency of http requests handled by server.",
+	nil, "method", "route", "status",
+)
+
+// responseWriter record the status written to the response, a bare Write
+// replies 200. Unwrap exposes the underlying writer to http.ResponseController.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader record the status of the first call only, the later ones are
+// ignored by the server.
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("rest: %T does not support hijacking", w.ResponseWriter)
+	}
+	return h.Hijack()
+}
+
+// serverMetrics observe the latency and status of each request, labelled by
+// the route pattern rather than the path to keep the series bounded.
+func serverMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, info := withRouteInfo(r)
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
+		route := info.pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		serverHandlingSeconds.ObserveDuration(time.Since(start), r.Method, route, strconv.Itoa(rw.status))
+	})
+}
+
+// serverTracing continue the trace from the request headers, and carry the
+// server span in the request context. The span is named by the method and the
+// route pattern once routed, or "unmatched", to keep the names bounded.
+func serverTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, info := withRouteInfo(r)
+		parent, _ := tracing.Extract(tracing.HeaderCarrier(r.Header))
+		ctx, span := tracing.StartSpanWithParent(r.Context(), parent, r.Method, tracing.KindServer)
+		span.SetAttribute("peer", r.RemoteAddr)
+
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		route := info.pattern
+		if route == "" {
+			route = "unmatched"
+		} else {
+			span.SetAttribute("route", route)
+		}
+		span.Name = r.Method + " " + route
+		var err error
+		if rw.status >= http.StatusInternalServerError {
+			err = fmt.Errorf("http status %d", rw.status)
+		}
+		span.Finish(err)
+	})
+}
+
+// withRouteInfo add the routeInfo filled by the router to the request, for
+// the outer handlers to know the matched route.
+func withRouteInfo(r *http.Request) (*http.Request, *routeInfo) {
+	if info, ok := r.Context().Value(routeInfoKey{}).(*routeInfo); ok {
+		return r, info
+	}
+	info := &routeInfo{}
+	return r.WithContext(context.WithValue(r.Context(), routeInfoKey{}, info)), info
+}
@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+)
+
+type HTTPOption func(*HTTPBundle)
+
+func HTTPListen(listenAddr string) HTTPOption {
+	return func(s *HTTPBundle) {
+		s.listenAddr = listenAddr
+	}
+}
+
+// HTTPDrainDelay set how long to wait after failing the health check before
+// shutting down, for the load balancers to stop sending requests.
+// Default: 0
+func HTTPDrainDelay(d time.Duration) HTTPOption {
+	return func(s *HTTPBundle) {
+		s.drainDelay = d
+	}
+}
+
+// HTTPDrainTimeout set how long the in-flight requests are waited on
+// shutdown, after which they are aborted.
+// Default: 20s
+func HTTPDrainTimeout(d time.Duration) HTTPOption {
+	return func(s *HTTPBundle) {
+		s.drainTimeout = d
+	}
+}
+
+// WithMiddlewares wrap the router by the middlewares, the first is the
+// outermost.
+func WithMiddlewares(middlewares ...func(http.Handler) http.Handler) HTTPOption {
+	return func(s *HTTPBundle) {
+		s.middlewares = append(s.middlewares, middlewares...)
+	}
+}
@@ -0,0 +1,159 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+// HTTPBundle serve the JSON HTTP endpoints added to its router, eg.
+//
+//	api := rest.NewHTTPBundle("api", rest.HTTPListen(":8080"))
+//	api.GET("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+//		rest.JSON(w, http.StatusOK, getUser(rest.Param(r, "id")))
+//	})
+//	app.AddBundle(api)
+//
+// The routes must be added before Run.
+type HTTPBundle struct {
+	*Router
+
+	name        string
+	listenAddr  string
+	middlewares []func(http.Handler) http.Handler
+
+	drainDelay   time.Duration
+	drainTimeout time.Duration
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	draining   atomic.Bool
+	ready      chan struct{}
+	readyOnce  sync.Once
+}
+
+func NewHTTPBundle(name string, opts ...HTTPOption) *HTTPBundle {
+	defaults := getDefaults()
+	s := &HTTPBundle{
+		Router:       NewRouter(),
+		name:         name,
+		listenAddr:   defaults.listenAddr,
+		drainDelay:   defaults.drainDelay,
+		drainTimeout: defaults.drainTimeout,
+		ready:        make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *HTTPBundle) Type() string {
+	return "http"
+}
+
+func (s *HTTPBundle) Name() string {
+	return s.name
+}
+
+func (s *HTTPBundle) ListenAddr() string {
+	return s.listenAddr
+}
+
+// Ready is closed once the bundle is listening.
+func (s *HTTPBundle) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Handler return the router wrapped by the middlewares, with the tracing and
+// metrics outermost.
+func (s *HTTPBundle) Handler() http.Handler {
+	var h http.Handler = s.Router
+	for i := range s.middlewares {
+		h = s.middlewares[len(s.middlewares)-1-i](h)
+	}
+	return serverTracing(serverMetrics(h))
+}
+
+func (s *HTTPBundle) Run(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/check_health", func(w http.ResponseWriter, _ *http.Request) {
+		if s.draining.Load() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("zhi~"))
+	})
+	mux.Handle("/", s.Handler())
+
+	httpServer := &http.Server{
+		Addr:           s.listenAddr,
+		Handler:        mux,
+		ReadTimeout:    5 * time.Second,
+		WriteTimeout:   5 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+	s.mu.Lock()
+	s.httpServer = httpServer
+	s.mu.Unlock()
+	// stopped before serving.
+	if s.draining.Load() {
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		return err
+	}
+	s.readyOnce.Do(func() {
+		close(s.ready)
+	})
+	err = httpServer.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Stop fail the health check first, wait the drain delay for the load
+// balancers to notice, then wait the in-flight requests until the drain
+// timeout, after which they are aborted. The context is done once drained.
+func (s *HTTPBundle) Stop() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		defer cancel()
+
+		s.draining.Store(true)
+		httpServer := s.getHTTPServer()
+		if httpServer == nil {
+			return
+		}
+		time.Sleep(s.drainDelay)
+
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), s.drainTimeout)
+		defer cancelShutdown()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Warnf("Drain http[%s] error: %v, force closing", s.name, err)
+			if err := httpServer.Close(); err != nil {
+				log.Errorf("Close http service error: %v", err)
+			}
+		}
+	}()
+
+	return ctx
+}
+
+func (s *HTTPBundle) getHTTPServer() *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.httpServer
+}
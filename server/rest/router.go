@@ -0,0 +1,204 @@
+package rest
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Router route the requests by method and path, the path patterns are made
+// of static segments, ":name" parameters matching one segment, and a final
+// "*name" matching the rest of the path, eg.
+//
+//	r.GET("/users/:id", getUser)
+//	r.GET("/static/*path", serveStatic)
+//
+// The static segments are preferred over the parameters, so "/users/me" is
+// routed before "/users/:id" whatever the order they are added.
+type Router struct {
+	routes map[string][]*route
+}
+
+type route struct {
+	pattern  string
+	segments []string
+	handler  http.Handler
+}
+
+// routeInfo carry the matched route of a request to the outer handlers.
+type routeInfo struct {
+	pattern string
+	params  map[string]string
+}
+
+type routeInfoKey struct{}
+
+func NewRouter() *Router {
+	return &Router{routes: make(map[string][]*route)}
+}
+
+// Handle add the handler of the method and path pattern, it panics if the
+// pattern is invalid or already added.
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	segments := splitPath(pattern)
+	for i, seg := range segments {
+		if (seg[0] == ':' || seg[0] == '*') && len(seg) == 1 {
+			panic("rest: unnamed parameter in pattern " + pattern)
+		}
+		if seg[0] == '*' && i != len(segments)-1 {
+			panic("rest: wildcard not at the end of pattern " + pattern)
+		}
+	}
+
+	method = strings.ToUpper(method)
+	for _, r := range rt.routes[method] {
+		if r.pattern == pattern {
+			panic("rest: duplicated route " + method + " " + pattern)
+		}
+	}
+	rt.routes[method] = append(rt.routes[method], &route{pattern: pattern, segments: segments, handler: h})
+	sort.SliceStable(rt.routes[method], func(i, j int) bool {
+		return morePrecise(rt.routes[method][i].segments, rt.routes[method][j].segments)
+	})
+}
+
+func (rt *Router) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	rt.Handle(method, pattern, h)
+}
+
+func (rt *Router) GET(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, h)
+}
+
+func (rt *Router) POST(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, h)
+}
+
+func (rt *Router) PUT(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodPut, pattern, h)
+}
+
+func (rt *Router) PATCH(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodPatch, pattern, h)
+}
+
+func (rt *Router) DELETE(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodDelete, pattern, h)
+}
+
+// ServeHTTP reply 404 if no pattern matches the path, or 405 with the allowed
+// methods if only the method does not match. HEAD is served by GET if no HEAD
+// pattern matches the path.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+
+	rte, params := match(rt.routes[r.Method], segments)
+	if rte == nil && r.Method == http.MethodHead {
+		rte, params = match(rt.routes[http.MethodGet], segments)
+	}
+	if rte != nil {
+		r, info := withRouteInfo(r)
+		info.pattern, info.params = rte.pattern, params
+		rte.handler.ServeHTTP(w, r)
+		return
+	}
+
+	var allowed []string
+	for m, routes := range rt.routes {
+		if rte, _ := match(routes, segments); rte != nil {
+			allowed = append(allowed, m)
+		}
+	}
+	if len(allowed) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	sort.Strings(allowed)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// Param return the path parameter of the request, empty if not exist.
+func Param(r *http.Request, name string) string {
+	if info, ok := r.Context().Value(routeInfoKey{}).(*routeInfo); ok {
+		return info.params[name]
+	}
+	return ""
+}
+
+// Route return the pattern matched by the request, empty if not routed.
+func Route(r *http.Request) string {
+	if info, ok := r.Context().Value(routeInfoKey{}).(*routeInfo); ok {
+		return info.pattern
+	}
+	return ""
+}
+
+func match(routes []*route, segments []string) (*route, map[string]string) {
+	for _, r := range routes {
+		if params, ok := r.match(segments); ok {
+			return r, params
+		}
+	}
+	return nil, nil
+}
+
+func (r *route) match(segments []string) (map[string]string, bool) {
+	var params map[string]string
+	setParam := func(name, value string) {
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = value
+	}
+
+	for i, seg := range r.segments {
+		switch seg[0] {
+		case '*':
+			setParam(seg[1:], strings.Join(segments[i:], "/"))
+			return params, true
+		case ':':
+			if i >= len(segments) {
+				return nil, false
+			}
+			setParam(seg[1:], segments[i])
+		default:
+			if i >= len(segments) || segments[i] != seg {
+				return nil, false
+			}
+		}
+	}
+	return params, len(segments) == len(r.segments)
+}
+
+// morePrecise compare the patterns segment by segment, a static segment is
+// more precise than a parameter, which is more precise than a wildcard.
+func morePrecise(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if ra, rb := segmentRank(a[i]), segmentRank(b[i]); ra != rb {
+			return ra < rb
+		}
+	}
+	return len(a) > len(b)
+}
+
+func segmentRank(seg string) int {
+	switch seg[0] {
+	case ':':
+		return 1
+	case '*':
+		return 2
+	default:
+		return 0
+	}
+}
+
+func splitPath(path string) []string {
+	var segments []string
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouterServeHTTP(t *testing.T) {
+	rt := NewRouter()
+	reply := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handler", name)
+			w.Header().Set("X-Route", Route(r))
+			w.Header().Set("X-Params", "id="+Param(r, "id")+" path="+Param(r, "path"))
+		}
+	}
+	// added before the static routes to check they are preferred whatever the order.
+	rt.GET("/users/:id", reply("getUser"))
+	rt.GET("/users/me", reply("getMe"))
+	rt.PUT("/users/:id", reply("putUser"))
+	rt.DELETE("/users/:id", reply("deleteUser"))
+	rt.GET("/users/:id/posts", reply("getPosts"))
+	rt.GET("/static/*path", reply("static"))
+	rt.GET("/static/index.html", reply("index"))
+	rt.POST("/upload", reply("upload"))
+	rt.HandleFunc(http.MethodHead, "/ping", reply("headPing"))
+	rt.GET("/ping", reply("getPing"))
+
+	tests := []struct {
+		method, path string
+		status       int
+		handler      string
+		route        string
+		params       string
+		allow        string
+	}{
+		{method: "GET", path: "/users/me", status: 200, handler: "getMe", route: "/users/me", params: "id= path="},
+		{method: "GET", path: "/users/42", status: 200, handler: "getUser", route: "/users/:id", params: "id=42 path="},
+		{method: "GET", path: "/users/42/", status: 200, handler: "getUser", route: "/users/:id", params: "id=42 path="},
+		{method: "PUT", path: "/users/me", status: 200, handler: "putUser", route: "/users/:id", params: "id=me path="},
+		{method: "GET", path: "/users/42/posts", status: 200, handler: "getPosts", route: "/users/:id/posts", params: "id=42 path="},
+		{method: "GET", path: "/static/index.html", status: 200, handler: "index", route: "/static/index.html", params: "id= path="},
+		{method: "GET", path: "/static/css/site.css", status: 200, handler: "static", route: "/static/*path", params: "id= path=css/site.css"},
+		{method: "GET", path: "/static", status: 200, handler: "static", route: "/static/*path", params: "id= path="},
+
+		// HEAD is served by GET if no HEAD pattern matches.
+		{method: "HEAD", path: "/users/42", status: 200, handler: "getUser", route: "/users/:id", params: "id=42 path="},
+		{method: "HEAD", path: "/ping", status: 200, handler: "headPing", route: "/ping", params: "id= path="},
+
+		{method: "POST", path: "/users/42", status: 405, allow: "DELETE, GET, PUT"},
+		{method: "GET", path: "/upload", status: 405, allow: "POST"},
+		{method: "GET", path: "/users", status: 404},
+		{method: "GET", path: "/users/42/comments", status: 404},
+		{method: "GET", path: "/", status: 404},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		name := tt.method + " " + tt.path
+		if w.Code != tt.status {
+			t.Errorf("%s status = %d, want %d", name, w.Code, tt.status)
+			continue
+		}
+		if got := w.Header().Get("X-Handler"); got != tt.handler {
+			t.Errorf("%s handler = %q, want %q", name, got, tt.handler)
+		}
+		if got := w.Header().Get("X-Route"); got != tt.route {
+			t.Errorf("%s route = %q, want %q", name, got, tt.route)
+		}
+		if got := w.Header().Get("X-Params"); got != tt.params {
+			t.Errorf("%s params = %q, want %q", name, got, tt.params)
+		}
+		if got := w.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s Allow = %q, want %q", name, got, tt.allow)
+		}
+	}
+}
+
+func TestRouterHandlePanics(t *testing.T) {
+	for _, pattern := range []string{"/users/:", "/static/*", "/static/*path/more", "/users/:id"} {
+		rt := NewRouter()
+		rt.GET("/users/:id", func(http.ResponseWriter, *http.Request) {})
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("GET %q did not panic", pattern)
+				}
+			}()
+			rt.GET(pattern, func(http.ResponseWriter, *http.Request) {})
+		}()
+	}
+}
+
+func TestMorePrecise(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/users/me", "/users/:id", true},
+		{"/users/:id", "/users/me", false},
+		{"/users/:id", "/users/*path", true},
+		{"/users/*path", "/users/:id", false},
+		{"/users/me", "/users/*path", true},
+		{"/users/:id/posts", "/users/:id", true},
+		{"/users/:id", "/users/:id/posts", false},
+		{"/users/:id/posts", "/users/me", false},
+		{"/users/:id", "/users/:name", false},
+		{"/a/:x/c", "/a/:x/:y", true},
+		{"/:x/b", "/a/:y", false},
+	}
+	for _, tt := range tests {
+		if got := morePrecise(splitPath(tt.a), splitPath(tt.b)); got != tt.want {
+			t.Errorf("morePrecise(%s, %s) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRouteOutsideRouter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	if got := Route(r); got != "" {
+		t.Errorf("Route = %q, want empty", got)
+	}
+	if got := Param(r, "id"); got != "" {
+		t.Errorf("Param = %q, want empty", got)
+	}
+}
@@ -8,17 +8,34 @@ import (
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
+
+	"github.com/YLeseclaireurs/icafe/breaker"
+	"github.com/YLeseclaireurs/icafe/server"
+	"github.com/YLeseclaireurs/icafe/tracing"
+	"github.com/YLeseclaireurs/icafe/utils"
 )
 
+// maxPickAddressTimes is the max times to pick an address not tried yet.
+const maxPickAddressTimes = 3
+
+// defaultOrigin is sent as the origin app if not specified.
+const defaultOrigin = "unknown"
+
 
 type Client struct {
-	targetName  string
-	timeout     time.Duration
-	serviceName string
-	discovery   *Discovery
-	hostPort    string
-	headers     map[string]string
-	client      *http.Client
+	targetName    string
+	timeout       time.Duration
+	serviceName   string
+	discovery     *Discovery
+	discoveryOpts []DiscoveryOption
+	hostPort      string
+	headers       map[string]string
+	client        *http.Client
+	retryPolicy   *RetryPolicy
+	breakers      *breaker.Group
+	origin        string
+	interceptors  []ClientInterceptor
+	invoker       Invoker
 }
 
 
@@ -28,10 +45,16 @@ type Option func(*Client)
 func TargetName(name string) Option {
 	return Option(func(c *Client) {
 		c.targetName = name
-		c.discovery = NewDiscovery(name)
 	})
 }
 
+// DiscoveryOptions customize the discovery used by TargetName, eg. the resolver.
+func DiscoveryOptions(opts ...DiscoveryOption) Option {
+	return func(c *Client) {
+		c.discoveryOpts = append(c.discoveryOpts, opts...)
+	}
+}
+
 // Timeout timeout specify the timeout for the underline transport.
 //
 // default value is 500ms.
@@ -56,6 +79,54 @@ func Url(url string) Option {
 	}
 }
 
+// Retry enable retrying the idempotent methods on transport errors, see RetryPolicy.
+func Retry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = &policy
+	}
+}
+
+// CircuitBreaker protect each address with a circuit breaker, a call to an
+// address whose breaker is open fails with breaker.ErrCircuitOpen.
+//
+// Only transport errors are counted as failures unless breaker.IsFailure is given.
+func CircuitBreaker(opts ...breaker.Option) Option {
+	return func(c *Client) {
+		c.breakers = breaker.NewGroup(append([]breaker.Option{breaker.IsFailure(isBreakerFailure)}, opts...)...)
+	}
+}
+
+// CircuitBreakerStates return the breaker state of each address called.
+func (c *Client) CircuitBreakerStates() map[string]breaker.State {
+	if c.breakers == nil {
+		return nil
+	}
+	return c.breakers.States()
+}
+
+// Interceptors intercept every call of the client, the first one is the outermost.
+func Interceptors(interceptors ...ClientInterceptor) Option {
+	return func(c *Client) {
+		c.interceptors = append(c.interceptors, interceptors...)
+	}
+}
+
+// Origin specify the app name sent to the remote service.
+//
+// default value is "unknown".
+func Origin(app string) Option {
+	return func(c *Client) {
+		c.origin = app
+	}
+}
+
+// OriginOf send the name of the application owning the client as the origin.
+func OriginOf(app server.Application) Option {
+	return func(c *Client) {
+		c.origin = app.Name()
+	}
+}
+
 // Headers Custom http headers
 func Headers(headers map[string]string) Option {
 	return func(c *Client) {
@@ -71,41 +142,171 @@ func (c *Client) SetHeader(key string, value string) {
 	c.headers[key] = value
 }
 
-func (t *Client) Call(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta,error) {
-	meta := thrift.ResponseMeta{}
+func (t *Client) Call(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+	return t.invoker(ctx, method, args, result)
+}
 
-	// Fetch address
-	var url string
-	var currentAddr *Address
+// invoke make the call with retry, it's the innermost of the interceptors.
+func (t *Client) invoke(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+	policy := t.retryPolicy
+	if policy == nil || !policy.idempotent(method) {
+		addr, err := t.pickAddress(nil)
+		if err != nil {
+			return thrift.ResponseMeta{}, err
+		}
+		return t.call(ctx, addr, method, args, result)
+	}
+
+	tried := map[string]struct{}{}
+	var meta thrift.ResponseMeta
+	var err error
+	for attempt := 1; attempt <= utils.MaxInt(policy.MaxAttempts, 1); attempt++ {
+		if attempt > 1 && !sleepContext(ctx, policy.backoff(attempt-1)) {
+			break
+		}
+
+		meta, err = t.hedgedCall(ctx, policy, tried, method, args, result)
+		if err == nil || !isRetryableError(err) || ctx.Err() != nil {
+			return meta, err
+		}
+	}
+	return meta, err
+}
+
+// hedgedCall fire another request to a different address each time no
+// response arrived after the hedge delay, return the first successful one.
+func (t *Client) hedgedCall(ctx context.Context, policy *RetryPolicy, tried map[string]struct{}, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+	if policy.HedgeDelay <= 0 || policy.MaxHedged <= 0 {
+		addr, err := t.pickAddress(tried)
+		if err != nil {
+			return thrift.ResponseMeta{}, err
+		}
+		return t.call(ctx, addr, method, args, result)
+	}
+
+	type reply struct {
+		meta   thrift.ResponseMeta
+		result thrift.TStruct
+		err    error
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	replies := make(chan reply, policy.MaxHedged+1)
+	launched, pending := 0, 0
+	launch := func() error {
+		addr, err := t.pickAddress(tried)
+		if err != nil {
+			return err
+		}
+		r, ok := newResultOf(result)
+		if !ok {
+			return fmt.Errorf("client: result %T can not be hedged", result)
+		}
+		launched++
+		pending++
+		go func() {
+			meta, err := t.call(ctx, addr, method, args, r)
+			replies <- reply{meta: meta, result: r, err: err}
+		}()
+		return nil
+	}
+	if err := launch(); err != nil {
+		return thrift.ResponseMeta{}, err
+	}
+
+	timer := time.NewTimer(policy.HedgeDelay)
+	defer timer.Stop()
+
+	var last reply
+	for pending > 0 {
+		select {
+		case r := <-replies:
+			pending--
+			if r.err == nil || !isRetryableError(r.err) {
+				copyResult(result, r.result)
+				return r.meta, r.err
+			}
+			last = r
+		case <-timer.C:
+			if launched <= policy.MaxHedged && launch() == nil {
+				timer.Reset(policy.HedgeDelay)
+			}
+		case <-ctx.Done():
+			return last.meta, ctx.Err()
+		}
+	}
+	return last.meta, last.err
+}
+
+// pickAddress fetch an address not tried yet if possible, the tried set may be nil.
+func (t *Client) pickAddress(tried map[string]struct{}) (*Address, error) {
 	if t.hostPort != "" {
-		url = "http://" + t.hostPort
-	} else {
+		return nil, nil
+	}
+
+	var addr *Address
+	for i := 0; i < maxPickAddressTimes; i++ {
 		var err error
-		currentAddr, err = t.discovery.GetAddress()
+		addr, err = t.discovery.GetAddress()
 		if err != nil {
-			return meta, fmt.Errorf("GetAddress failed for %s. Error:%s\n", t.targetName, err)
+			return nil, fmt.Errorf("GetAddress failed for %s. Error:%s\n", t.targetName, err)
+		}
+		if _, ok := tried[addr.String()]; !ok {
+			break
 		}
-		url = "http://" + currentAddr.String()
+	}
+	if tried != nil {
+		tried[addr.String()] = struct{}{}
+	}
+	return addr, nil
+}
+
+// call make a single request to addr, or the HostPort if addr is nil.
+func (t *Client) call(ctx context.Context, currentAddr *Address, method string, args, result thrift.TStruct) (meta thrift.ResponseMeta, err error) {
+	hostPort := t.hostPort
+	if currentAddr != nil {
+		hostPort = currentAddr.String()
+	}
+	url := "http://" + hostPort
+
+	ctx, span := tracing.StartSpan(ctx, t.serviceName+"."+method, tracing.KindClient)
+	span.SetAttribute("peer", hostPort)
+	start := time.Now()
+	defer func() {
+		span.Finish(err)
+		clientHandlingSeconds.ObserveDuration(time.Since(start), t.serviceName, method, clientStatus(err))
+	}()
+
+	if t.breakers != nil {
+		b := t.breakers.Get(hostPort)
+		if err := b.Allow(); err != nil {
+			return thrift.ResponseMeta{}, err
+		}
+		defer func() {
+			b.Done(err, time.Since(start))
+		}()
 	}
 
 	// Make transport
-	transport := newTransport(ctx, t.client, url, t.serviceName, method, t.headers)
+	transport := newTransport(ctx, t.client, url, t.serviceName, method, t.origin, t.headers)
 
 	// Make protocol
 	protocol := newProtocol(transport, t.serviceName)
 
 	// Make real request
 	conn := thrift.NewTStandardClient(protocol, protocol)
-	var err error
-	meta,err = conn.Call(ctx, method, args, result);
-	if  err != nil {
-		if _, ok := err.(thrift.TTransportException); ok && currentAddr != nil {
+	meta, err = conn.Call(ctx, method, args, result)
+	if err != nil {
+		// The canceled requests, eg. the hedged losers, say nothing about the address.
+		if _, ok := err.(thrift.TTransportException); ok && currentAddr != nil && ctx.Err() == nil {
 			t.discovery.DiscardAddress(currentAddr)
 		}
 	}
 
 	_ = protocol.Transport().Close()
-	return meta,err
+	return meta, err
 }
 
 // New create a new tzone client with specified service and options.
@@ -113,16 +314,23 @@ func New(serviceName string, opts ...Option) *Client {
 	c := &Client{
 		timeout:     500 * time.Millisecond,
 		serviceName: serviceName,
+		origin:      defaultOrigin,
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
 
+	if c.targetName != "" && c.hostPort == "" {
+		c.discovery = NewDiscovery(c.targetName, c.discoveryOpts...)
+	}
+
 	if c.discovery == nil && c.hostPort == "" {
 		panic("client: either targetName or HostPort option must be specified.")
 	}
 
+	c.invoker = chainInterceptors(c.serviceName, c.interceptors, c.invoke)
+
 	// fork from https://github.com/golang/go/blob/release-branch.go1.11/src/net/http/transport.go#L42
 	c.client = &http.Client{
 		Timeout: c.timeout,
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/server"
+	"github.com/YLeseclaireurs/icafe/tomlconfig"
+)
+
+// bundleSection is the config section of a TRPCBundle, eg.
+//
+//	[rpc.content]
+//	listen = "0.0.0.0:9000"
+//	drain_delay = "5s"
+type bundleSection struct {
+	Listen       string        `toml:"listen" validate:"hostport"`
+	DrainDelay   time.Duration `toml:"drain_delay"`
+	DrainTimeout time.Duration `toml:"drain_timeout"`
+}
+
+// NewTRPCBundleFromConfig create a TRPCBundle from the config section, the
+// options override the section.
+func NewTRPCBundleFromConfig(name string, conf *tomlconfig.Config, opts ...TRPCOption) (server.Bundle, error) {
+	var s bundleSection
+	if err := conf.Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode tzone bundle [%s] config error %s", name, err)
+	}
+
+	var sectionOpts []TRPCOption
+	if s.Listen != "" {
+		sectionOpts = append(sectionOpts, TRPCListen(s.Listen))
+	}
+	if s.DrainDelay > 0 {
+		sectionOpts = append(sectionOpts, TRPCDrainDelay(s.DrainDelay))
+	}
+	if s.DrainTimeout > 0 {
+		sectionOpts = append(sectionOpts, TRPCDrainTimeout(s.DrainTimeout))
+	}
+	return NewTRPCBundle(name, append(sectionOpts, opts...)...), nil
+}
+
+// clientSection is the config section of a Client, eg.
+//
+//	[rpc.clients.content]
+//	target = "content-thrift"
+//	timeout = "500ms"
+type clientSection struct {
+	Target  string            `toml:"target"`
+	URL     string            `toml:"url"`
+	Host    string            `toml:"host"`
+	Port    string            `toml:"port"`
+	Timeout time.Duration     `toml:"timeout"`
+	Origin  string            `toml:"origin"`
+	Headers map[string]string `toml:"headers"`
+}
+
+// NewFromConfig create a Client of the service from the config section, the
+// options override the section, eg.
+//
+//	rpc.NewFromConfig("ContentService", app.Config().Sub("rpc.clients.content"), rpc.OriginOf(app))
+func NewFromConfig(serviceName string, conf *tomlconfig.Config, opts ...Option) (*Client, error) {
+	var s clientSection
+	if err := conf.Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode tzone client [%s] config error %s", serviceName, err)
+	}
+	if s.Target == "" && s.Host == "" && s.URL == "" {
+		return nil, fmt.Errorf("tzone client [%s] config without target, host or url", serviceName)
+	}
+
+	var sectionOpts []Option
+	if s.Target != "" {
+		sectionOpts = append(sectionOpts, TargetName(s.Target))
+	}
+	if s.Host != "" {
+		sectionOpts = append(sectionOpts, HostPort(s.Host, s.Port))
+	}
+	if s.URL != "" {
+		sectionOpts = append(sectionOpts, Url(s.URL))
+	}
+	if s.Timeout > 0 {
+		sectionOpts = append(sectionOpts, Timeout(s.Timeout))
+	}
+	if s.Origin != "" {
+		sectionOpts = append(sectionOpts, Origin(s.Origin))
+	}
+	if len(s.Headers) > 0 {
+		sectionOpts = append(sectionOpts, Headers(s.Headers))
+	}
+	return New(serviceName, append(sectionOpts, opts...)...), nil
+}
@@ -1,15 +1,20 @@
 package rpc
 
+import "time"
+
 type Defaults struct {
 	Name string
 
-	listenAddr string
+	listenAddr   string
+	drainDelay   time.Duration
+	drainTimeout time.Duration
 }
 
 func getDefaults() Defaults {
 	d := Defaults{
-		Name:       "name",
-		listenAddr: "0.0.0.0:8000",
+		Name:         "name",
+		listenAddr:   "0.0.0.0:8000",
+		drainTimeout: 20 * time.Second,
 	}
 
 	return d
@@ -1,9 +1,19 @@
 package rpc
 
 import (
-	"strings"
+	"context"
+	"fmt"
+	"net"
 	"sync"
 	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+const (
+	resolveTimeout = time.Second
+	// minRefreshInterval limit the refreshes when all the hosts are discarded.
+	minRefreshInterval = time.Second
 )
 
 // Discovery for target app address, with load balancing and fail over.
@@ -14,7 +24,7 @@ import (
 //    2. Once a connection to a specified host failed, discard the host and
 //       try the next. And loop the process until find a connectable host
 //       or the retry time reaches the max time. If all the hosts are
-//       discarded, refresh the host list.
+//       discarded, refresh the host list, at most once per second.
 //    3. The discovery also accepts an extra address pair, for fallback
 //       when the discovery agent is down. If no backup address found, zone
 //       will try to pick one from the discarded addresses.
@@ -31,15 +41,24 @@ type Discovery struct {
 	ttl time.Duration
 
 	// the real woker to fetch the service list.
-	// diplomat *diplomat.Diplomat
+	resolver Resolver
+
+	// the service list fetched at the last refresh.
+	addrs []*Address
 
-	// record the last time the address is acessed,
-	// used for refreshing the service list after time expired.
-	lastCleanupTime *time.Time
+	// lastRefresh is when the last refresh started, refreshing is whether a
+	// refresh is in flight, resolved is closed once the first one finished.
+	lastRefresh  time.Time
+	refreshing   bool
+	resolved     chan struct{}
+	resolvedOnce sync.Once
 
 	// record the unnormal address to prevent failure.
 	discardedAddrs map[string]struct{}
 
+	// round robin cursor.
+	next uint64
+
 	mu sync.Mutex
 }
 
@@ -49,13 +68,16 @@ type Address struct {
 }
 
 // newAddressFromString accept a string in "ip:port" format.
-func newAddressFromString(addrString string) *Address {
-	splitedAddr := strings.Split(addrString, ":")
+func newAddressFromString(addrString string) (*Address, error) {
+	host, port, err := net.SplitHostPort(addrString)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Address{
-		IP:   splitedAddr[0],
-		Port: splitedAddr[1],
-	}
+		IP:   host,
+		Port: port,
+	}, nil
 }
 
 func (addr *Address) String() string {
@@ -70,61 +92,149 @@ func (addr *Address) Valid() bool {
 	return false
 }
 
-func NewDiscovery(targetName string) *Discovery {
-	return &Discovery{
-		target:         targetName,
-		ttl:            10 * time.Second,
-		//diplomat:       diplomat.Discover(),
-		discardedAddrs: map[string]struct{}{},
+type DiscoveryOption func(*Discovery)
+
+// DiscoveryResolver specify the resolver to fetch the service list.
+//
+// default value is DefaultResolver().
+func DiscoveryResolver(r Resolver) DiscoveryOption {
+	return func(d *Discovery) {
+		d.resolver = r
 	}
 }
 
-func NewDiscoveryWithFallback(targetName string, address *Address) *Discovery {
-	return &Discovery{
+// DiscoveryTTL specify the interval to refresh the service list.
+//
+// default value is 10s.
+func DiscoveryTTL(ttl time.Duration) DiscoveryOption {
+	return func(d *Discovery) {
+		d.ttl = ttl
+	}
+}
+
+// DiscoveryFallback specify the address used when no address can be resolved.
+func DiscoveryFallback(address *Address) DiscoveryOption {
+	return func(d *Discovery) {
+		d.address = address
+	}
+}
+
+func NewDiscovery(targetName string, opts ...DiscoveryOption) *Discovery {
+	d := &Discovery{
 		target:         targetName,
-		address:        address,
 		ttl:            10 * time.Second,
-		//diplomat:       diplomat.Discover(),
+		resolver:       DefaultResolver(),
 		discardedAddrs: map[string]struct{}{},
+		resolved:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(d)
 	}
+
+	return d
+}
+
+func NewDiscoveryWithFallback(targetName string, address *Address, opts ...DiscoveryOption) *Discovery {
+	return NewDiscovery(targetName, append([]DiscoveryOption{DiscoveryFallback(address)}, opts...)...)
 }
 
-// GetAddress try to get a usable address from consul.
+// GetAddress try to get a usable address from the resolver. Only the first
+// call waits for the resolver, the later refreshes run in the background
+// while the last list is served.
 func (d *Discovery) GetAddress() (*Address, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
+	now := time.Now()
+	if d.addrs == nil {
+		d.startRefresh(now)
+		resolved := d.resolved
+		d.mu.Unlock()
+		<-resolved
+		d.mu.Lock()
+	} else if now.Sub(d.lastRefresh) >= d.ttl {
+		// forget the discarded hosts with the expired list.
+		d.discardedAddrs = map[string]struct{}{}
+		d.startRefresh(now)
+	}
 
-	consulServiceMap := map[string]*Address {
-		"zvideo-service": &Address{
-			"0.0.0.0",
-			"8000",
-		},
+	candidates := d.availableAddrs()
+	if len(candidates) == 0 && now.Sub(d.lastRefresh) >= minRefreshInterval {
+		// All the hosts are discarded, refresh the host list.
+		d.startRefresh(now)
 	}
 
-	return consulServiceMap[d.target],nil
+	if len(candidates) == 0 {
+		if d.address != nil && d.address.Valid() {
+			return d.address, nil
+		}
+		if len(d.addrs) > 0 {
+			candidates = d.addrs
+		} else {
+			return nil, fmt.Errorf("no address available for %s", d.target)
+		}
+	}
 
-	// 服务发现，得到IP:PORT;
-	// return  &Address{IP: "0.0.0.0", Port: "9000"}, nil
+	d.next++
+	return candidates[d.next%uint64(len(candidates))], nil
 }
 
-func (d *Discovery) DiscardAddress(address *Address) {
+// startRefresh refresh the service list in the background, unless a refresh
+// is in flight. The lock must be held.
+func (d *Discovery) startRefresh(now time.Time) {
+	if d.refreshing {
+		return
+	}
+	d.refreshing = true
+	d.lastRefresh = now
+	go d.refresh()
+}
+
+// refresh fetch the service list from resolver, keep the last list if failed.
+func (d *Discovery) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addrs, err := d.resolver.Resolve(ctx, d.target)
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
-
-	if address == nil {
+	defer d.resolvedOnce.Do(func() {
+		close(d.resolved)
+	})
+	d.refreshing = false
+
+	if err != nil {
+		if len(d.addrs) == 0 {
+			log.Errorf("Resolve %s error: %v", d.target, err)
+		} else {
+			log.Warnf("Resolve %s error: %v, keep the last %d addresses", d.target, err, len(d.addrs))
+		}
+		if d.addrs == nil {
+			d.addrs = []*Address{}
+		}
 		return
 	}
-	d.discardedAddrs[address.String()] = struct{}{}
+	d.addrs = addrs
+	d.discardedAddrs = map[string]struct{}{}
 }
 
-func (d *Discovery) cleanup() {
-	// Don't lock
-	now := time.Now()
-	if d.lastCleanupTime != nil {
-		if d.lastCleanupTime.Add(d.ttl).Before(now) {
-			d.discardedAddrs = map[string]struct{}{}
-			d.lastCleanupTime = &now
+func (d *Discovery) availableAddrs() []*Address {
+	addrs := make([]*Address, 0, len(d.addrs))
+	for _, addr := range d.addrs {
+		if _, ok := d.discardedAddrs[addr.String()]; !ok {
+			addrs = append(addrs, addr)
 		}
-	} else {
-		d.lastCleanupTime = &now
 	}
+	return addrs
+}
+
+func (d *Discovery) DiscardAddress(address *Address) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if address == nil {
+		return
+	}
+	d.discardedAddrs[address.String()] = struct{}{}
 }
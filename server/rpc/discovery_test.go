@@ -0,0 +1,217 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testResolver return the addresses set, and count the calls.
+type testResolver struct {
+	mu    sync.Mutex
+	addrs []string
+	err   error
+	calls int
+}
+
+func (r *testResolver) set(err error, addrs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs, r.err = addrs, err
+}
+
+func (r *testResolver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func (r *testResolver) Resolve(ctx context.Context, target string) ([]*Address, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	addrs := make([]*Address, 0, len(r.addrs))
+	for _, s := range r.addrs {
+		addr, err := newAddressFromString(s)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// waitRefreshed wait until the resolver called n times and the refresh done.
+func waitRefreshed(t *testing.T, d *Discovery, r *testResolver, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		d.mu.Lock()
+		refreshing := d.refreshing
+		d.mu.Unlock()
+		if r.count() >= n && !refreshing {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("resolver called %d times, want %d", r.count(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func getAddresses(t *testing.T, d *Discovery, n int) []string {
+	t.Helper()
+	var got []string
+	for i := 0; i < n; i++ {
+		addr, err := d.GetAddress()
+		if err != nil {
+			t.Fatalf("GetAddress error = %v", err)
+		}
+		got = append(got, addr.String())
+	}
+	return got
+}
+
+func TestDiscoveryRoundRobin(t *testing.T) {
+	r := &testResolver{}
+	r.set(nil, "10.0.0.1:9000", "10.0.0.2:9000", "10.0.0.3:9000")
+	d := NewDiscovery("svc", DiscoveryResolver(r))
+
+	got := getAddresses(t, d, 6)
+	counts := map[string]int{}
+	for i, addr := range got {
+		counts[addr]++
+		if i > 0 && addr == got[i-1] {
+			t.Errorf("the same address twice in a row: %v", got)
+		}
+	}
+	if len(counts) != 3 {
+		t.Errorf("addresses = %v, want each of the 3", got)
+	}
+	for addr, n := range counts {
+		if n != 2 {
+			t.Errorf("%s got %d times, want 2", addr, n)
+		}
+	}
+	if n := r.count(); n != 1 {
+		t.Errorf("resolver called %d times, want 1 in the ttl", n)
+	}
+}
+
+func TestDiscoveryDiscard(t *testing.T) {
+	tests := []struct {
+		name     string
+		fallback *Address
+		discard  []string
+		want     []string
+	}{
+		{"one discarded", nil, []string{"10.0.0.1:9000"}, []string{"10.0.0.2:9000"}},
+		{"all discarded", nil, []string{"10.0.0.1:9000", "10.0.0.2:9000"}, []string{"10.0.0.1:9000", "10.0.0.2:9000"}},
+		{"all discarded with fallback", &Address{IP: "10.0.1.1", Port: "9000"},
+			[]string{"10.0.0.1:9000", "10.0.0.2:9000"}, []string{"10.0.1.1:9000"}},
+		{"invalid fallback", &Address{IP: "10.0.1.1"},
+			[]string{"10.0.0.1:9000", "10.0.0.2:9000"}, []string{"10.0.0.1:9000", "10.0.0.2:9000"}},
+	}
+	for _, tt := range tests {
+		r := &testResolver{}
+		r.set(nil, "10.0.0.1:9000", "10.0.0.2:9000")
+		d := NewDiscoveryWithFallback("svc", tt.fallback, DiscoveryResolver(r))
+		getAddresses(t, d, 1)
+		for _, s := range tt.discard {
+			addr, _ := newAddressFromString(s)
+			d.DiscardAddress(addr)
+		}
+
+		want := map[string]bool{}
+		for _, s := range tt.want {
+			want[s] = true
+		}
+		seen := map[string]bool{}
+		for _, addr := range getAddresses(t, d, 4) {
+			if !want[addr] {
+				t.Errorf("%s: got %s, want one of %v", tt.name, addr, tt.want)
+			}
+			seen[addr] = true
+		}
+		if len(seen) != len(want) {
+			t.Errorf("%s: got %v, want all of %v", tt.name, seen, tt.want)
+		}
+	}
+}
+
+func TestDiscoveryRefreshAllDiscarded(t *testing.T) {
+	r := &testResolver{}
+	r.set(nil, "10.0.0.1:9000")
+	d := NewDiscovery("svc", DiscoveryResolver(r))
+	getAddresses(t, d, 1)
+
+	addr, _ := newAddressFromString("10.0.0.1:9000")
+	d.DiscardAddress(addr)
+	r.set(nil, "10.0.0.2:9000")
+
+	// rate limited, at most once per minRefreshInterval.
+	getAddresses(t, d, 3)
+	if n := r.count(); n != 1 {
+		t.Fatalf("resolver called %d times, want 1", n)
+	}
+
+	d.mu.Lock()
+	d.lastRefresh = d.lastRefresh.Add(-minRefreshInterval)
+	d.mu.Unlock()
+	getAddresses(t, d, 1)
+	waitRefreshed(t, d, r, 2)
+
+	// the discarded hosts are forgot with the new list.
+	for _, addr := range getAddresses(t, d, 2) {
+		if addr != "10.0.0.2:9000" {
+			t.Errorf("got %s after refreshed, want 10.0.0.2:9000", addr)
+		}
+	}
+	if n := r.count(); n != 2 {
+		t.Errorf("resolver called %d times, want 2", n)
+	}
+}
+
+func TestDiscoveryTTL(t *testing.T) {
+	r := &testResolver{}
+	r.set(nil, "10.0.0.1:9000")
+	d := NewDiscovery("svc", DiscoveryResolver(r), DiscoveryTTL(time.Millisecond))
+	getAddresses(t, d, 1)
+
+	// a failed refresh keeps the last list.
+	r.set(errors.New("agent down"))
+	time.Sleep(2 * time.Millisecond)
+	getAddresses(t, d, 1)
+	waitRefreshed(t, d, r, 2)
+	if got := getAddresses(t, d, 1)[0]; got != "10.0.0.1:9000" {
+		t.Errorf("got %s after failed refresh, want the last 10.0.0.1:9000", got)
+	}
+
+	r.set(nil, "10.0.0.2:9000")
+	time.Sleep(2 * time.Millisecond)
+	getAddresses(t, d, 1)
+	waitRefreshed(t, d, r, 3)
+	if got := getAddresses(t, d, 1)[0]; got != "10.0.0.2:9000" {
+		t.Errorf("got %s after refreshed, want 10.0.0.2:9000", got)
+	}
+}
+
+func TestDiscoveryNoAddress(t *testing.T) {
+	r := &testResolver{}
+	r.set(ErrNoAddress)
+	d := NewDiscovery("svc", DiscoveryResolver(r))
+	if addr, err := d.GetAddress(); err == nil {
+		t.Errorf("GetAddress = %s, want error", addr)
+	}
+
+	fallback := &Address{IP: "10.0.1.1", Port: "9000"}
+	d = NewDiscoveryWithFallback("svc", fallback, DiscoveryResolver(r))
+	if addr, err := d.GetAddress(); err != nil || addr != fallback {
+		t.Errorf("GetAddress = %v, %v, want the fallback", addr, err)
+	}
+}
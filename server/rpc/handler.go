@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/apache/thrift/lib/go/thrift"
+
+	"github.com/YLeseclaireurs/icafe/log"
+	"github.com/YLeseclaireurs/icafe/tracing"
+)
+
+const thriftContentType = "application/x-thrift"
+
+// Handler decode a multiplexed binary protocol request and dispatch it to the
+// registered processors.
+//
+// Exceptions already encoded into the response envelope by the processor
+// (eg. the handler returned an error) are replied with 200, because thrift
+// http clients only decode the body of a 200 response. Failures before the
+// envelope can be dispatched are mapped to http statuses:
+//
+//	405  not a POST request
+//	400  malformed message or arguments
+//	404  unknown service or method
+//	503  request abandoned by the handler
+//	500  any other error
+func (s *Server) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	out := thrift.NewTMemoryBuffer()
+	iprot := s.protocolFactory.GetProtocol(thrift.NewStreamTransportR(r.Body))
+	oprot := s.protocolFactory.GetProtocol(out)
+
+	status := s.process(r.Context(), iprot, oprot)
+
+	w.Header().Set("Content-Type", thriftContentType)
+	w.WriteHeader(status)
+	if out.Len() > 0 {
+		_, _ = w.Write(out.Bytes())
+	}
+}
+
+func (s *Server) process(ctx context.Context, iprot, oprot thrift.TProtocol) int {
+	name, typeID, seqID, err := iprot.ReadMessageBegin(ctx)
+	if err != nil {
+		log.ErrorContextf(ctx, "Read thrift message error: %v", err)
+		return http.StatusBadRequest
+	}
+
+	service := strings.SplitN(name, thrift.MULTIPLEXED_SEPARATOR, 2)[0]
+	if _, ok := s.services[service]; !ok {
+		log.ErrorContextf(ctx, "Unknown thrift service: %s", name)
+		return http.StatusNotFound
+	}
+
+	ok, exc := s.processor.Process(ctx, thrift.NewStoredMessageProtocol(iprot, name, typeID, seqID), oprot)
+	if exc == nil {
+		return http.StatusOK
+	}
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		span.RecordError(exc)
+	}
+	if ok {
+		log.ErrorContextf(ctx, "Process %s error: %v", name, exc)
+		return http.StatusOK
+	}
+
+	log.ErrorContextf(ctx, "Process %s failed: %v", name, exc)
+	return statusOfException(exc)
+}
+
+func statusOfException(exc thrift.TException) int {
+	if errors.Is(exc, thrift.ErrAbandonRequest) {
+		return http.StatusServiceUnavailable
+	}
+
+	var appExc thrift.TApplicationException
+	if errors.As(exc, &appExc) {
+		switch appExc.TypeId() {
+		case thrift.UNKNOWN_METHOD, thrift.WRONG_METHOD_NAME:
+			return http.StatusNotFound
+		case thrift.PROTOCOL_ERROR, thrift.INVALID_MESSAGE_TYPE_EXCEPTION, thrift.INVALID_PROTOCOL:
+			return http.StatusBadRequest
+		}
+		return http.StatusInternalServerError
+	}
+
+	var protoExc thrift.TProtocolException
+	if errors.As(exc, &protoExc) {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// Invoker make the call of method to the remote service.
+type Invoker func(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error)
+
+// ClientInterceptor intercept the calls of a client, eg. logging, metrics,
+// auth and tracing. It must call invoker to make the real call.
+type ClientInterceptor func(ctx context.Context, service, method string, args, result thrift.TStruct, invoker Invoker) (thrift.ResponseMeta, error)
+
+// chainInterceptors wrap invoker with interceptors, the first one is the outermost.
+func chainInterceptors(service string, interceptors []ClientInterceptor, invoker Invoker) Invoker {
+	for i := range interceptors {
+		interceptor := interceptors[len(interceptors)-1-i]
+		next := invoker
+		invoker = func(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+			return interceptor(ctx, service, method, args, result, next)
+		}
+	}
+	return invoker
+}
+
+type outgoingHeadersKey struct{}
+
+// WithOutgoingHeader attach a http header to the calls made with the returned
+// context, eg. an auth token. The X-ZONE headers are reserved.
+func WithOutgoingHeader(ctx context.Context, key, value string) context.Context {
+	headers := OutgoingHeaders(ctx)
+	copied := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		copied[k] = v
+	}
+	copied[key] = value
+	return context.WithValue(ctx, outgoingHeadersKey{}, copied)
+}
+
+// OutgoingHeaders return the headers attached by WithOutgoingHeader.
+func OutgoingHeaders(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(outgoingHeadersKey{}).(map[string]string)
+	return headers
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/metrics"
+	"github.com/YLeseclaireurs/icafe/tracing"
+)
+
+var (
+	serverHandlingSeconds = metrics.NewHistogram(
+		"thrift_server_handling_seconds",
+		"Latency of thrift requests handled by server.",
+		nil, "service", "method", "status",
+	)
+	clientHandlingSeconds = metrics.NewHistogram(
+		"thrift_client_handling_seconds",
+		"Latency of thrift calls made by client.",
+		nil, "service", "method", "status",
+	)
+)
+
+// serverMetrics observe the latency and status of each request, status is
+// the http status, or "exception" if an exception replied with 200.
+func serverMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+
+		status := strconv.Itoa(rw.status)
+		if span := tracing.SpanFromContext(r.Context()); rw.status == http.StatusOK && span != nil && span.Err != nil {
+			status = "exception"
+		}
+		service, method := splitAPI(apiName(r))
+		serverHandlingSeconds.ObserveDuration(time.Since(start), service, method, status)
+	})
+}
+
+func clientStatus(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case isRetryableError(err):
+		return "transport_error"
+	default:
+		return "error"
+	}
+}
+
+// splitAPI split "service.method" sent by client.
+func splitAPI(api string) (service, method string) {
+	if i := strings.LastIndexByte(api, '.'); i >= 0 {
+		return api[:i], api[i+1:]
+	}
+	return "", api
+}
@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+
+	"github.com/YLeseclaireurs/icafe/log"
+	"github.com/YLeseclaireurs/icafe/tracing"
+)
+
+// RequestIDHeader carry the request id between the services.
+const RequestIDHeader = "X-Request-Id"
+
+// The standard server middlewares, to pass to WithMiddlewares, eg.
+//
+//	rpc.WithMiddlewares(
+//		rpc.RequestID(),
+//		rpc.AccessLog(),
+//		rpc.Recovery(),
+//		rpc.ServerTimeout(time.Second, map[string]time.Duration{"export_contents": time.Minute}),
+//	)
+//
+// RequestID should come first for the other middlewares to log the id.
+
+// RequestID take the request id from the RequestIDHeader, or generate one,
+// and store it in the context by log.ContextWithRequestID. The id is replied
+// in the response header, and sent by the clients called with the context.
+func RequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(log.ContextWithRequestID(r.Context(), requestID)))
+		})
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+// AccessLog log each request with the service and method sent by the client,
+// the latency, the status and the peer. The status is the http status, or
+// "exception" if an exception replied with 200.
+func AccessLog() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := newResponseWriter(w)
+			next.ServeHTTP(rw, r)
+
+			status := strconv.Itoa(rw.status)
+			if span := tracing.SpanFromContext(r.Context()); rw.status == http.StatusOK && span != nil && span.Err != nil {
+				status = "exception"
+			}
+			service, method := splitAPI(apiName(r))
+			log.WithContext(r.Context()).WithFields(log.Fields{
+				"service": service,
+				"method":  method,
+				"latency": time.Since(start).String(),
+				"status":  status,
+				"peer":    r.RemoteAddr,
+				"origin":  r.Header.Get("X-ZONE-ORIGIN-APP"),
+			}).Info("access")
+		})
+	}
+}
+
+// ServerTimeout set the deadline of the request context, by the timeout of
+// the "service.method" or the method in methods, or d otherwise. No deadline
+// is set if the timeout is 0.
+func ServerTimeout(d time.Duration, methods map[string]time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := d
+			api := apiName(r)
+			_, method := splitAPI(api)
+			if t, ok := methods[api]; ok {
+				timeout = t
+			} else if t, ok := methods[method]; ok {
+				timeout = t
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Recovery recover the panics of the handlers, and reply an INTERNAL_ERROR
+// application exception for the client to raise, or 500 if the request
+// message can't be read.
+func Recovery() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// keep the head of the body, to reply the exception with the name
+			// and sequence id of the message.
+			head := &headBuffer{limit: 1024}
+			r.Body = readCloser{Reader: io.TeeReader(r.Body, head), Closer: r.Body}
+			rw := newResponseWriter(w)
+
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				err := fmt.Errorf("panic: %v", p)
+				log.ErrorContextf(r.Context(), "Handle %s %v\n%s", apiName(r), err, debug.Stack())
+				if span := tracing.SpanFromContext(r.Context()); span != nil {
+					span.RecordError(err)
+				}
+				if rw.wroteHeader {
+					return
+				}
+
+				body, ok := exceptionMessage(r.Context(), head.Bytes())
+				if !ok {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", thriftContentType)
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write(body)
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// exceptionMessage encode an INTERNAL_ERROR exception replying the message
+// in head.
+func exceptionMessage(ctx context.Context, head []byte) ([]byte, bool) {
+	factory := thrift.NewTBinaryProtocolFactoryDefault()
+	name, _, seqID, readErr := factory.GetProtocol(thrift.NewStreamTransportR(bytes.NewReader(head))).ReadMessageBegin(ctx)
+	if readErr != nil {
+		return nil, false
+	}
+	// the replies of a multiplexed processor are named by the method only.
+	if i := strings.Index(name, thrift.MULTIPLEXED_SEPARATOR); i >= 0 {
+		name = name[i+len(thrift.MULTIPLEXED_SEPARATOR):]
+	}
+
+	out := thrift.NewTMemoryBuffer()
+	oprot := factory.GetProtocol(out)
+	exc := thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "Internal error processing "+name)
+	if oprot.WriteMessageBegin(ctx, name, thrift.EXCEPTION, seqID) != nil ||
+		exc.Write(ctx, oprot) != nil ||
+		oprot.WriteMessageEnd(ctx) != nil ||
+		oprot.Flush(ctx) != nil {
+		return nil, false
+	}
+	return out.Bytes(), true
+}
+
+// headBuffer keep the first limit bytes written.
+type headBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if n := b.limit - len(b.buf); n > 0 {
+		if len(p) < n {
+			n = len(p)
+		}
+		b.buf = append(b.buf, p[:n]...)
+	}
+	return len(p), nil
+}
+
+func (b *headBuffer) Bytes() []byte {
+	return b.buf
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -3,6 +3,7 @@ package rpc
 import (
 	"github.com/apache/thrift/lib/go/thrift"
 	"net/http"
+	"time"
 )
 
 type TRPCOption func(*TRPCBundle)
@@ -13,6 +14,24 @@ func TRPCListen(listenAddr string) TRPCOption {
 	}
 }
 
+// TRPCDrainDelay set how long to wait after failing the health check before
+// shutting down, for the load balancers to stop sending requests.
+// Default: 0
+func TRPCDrainDelay(d time.Duration) TRPCOption {
+	return func(s *TRPCBundle) {
+		s.drainDelay = d
+	}
+}
+
+// TRPCDrainTimeout set how long the in-flight requests are waited on
+// shutdown, after which they are aborted.
+// Default: 20s
+func TRPCDrainTimeout(d time.Duration) TRPCOption {
+	return func(s *TRPCBundle) {
+		s.drainTimeout = d
+	}
+}
+
 func WithTRPCServiceMap(serviceMap map[string]thrift.TProcessor) TRPCOption {
 	return func(s *TRPCBundle) {
 		s.serviceMap = serviceMap
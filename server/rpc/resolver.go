@@ -0,0 +1,199 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// ErrNoAddress is returned when a resolver knows nothing about the target.
+var ErrNoAddress = errors.New("no address found")
+
+// Resolver fetch the address list of the specified target service.
+type Resolver interface {
+	Resolve(ctx context.Context, target string) ([]*Address, error)
+}
+
+// defaultResolver hold the resolverHolder of the discoveries created without
+// a resolver.
+var defaultResolver atomic.Value
+
+func init() {
+	defaultResolver.Store(resolverHolder{NewDNSResolver("", "")})
+}
+
+// resolverHolder keep the stored type the same for atomic.Value.
+type resolverHolder struct {
+	Resolver
+}
+
+// DefaultResolver return the resolver used by the discoveries created without
+// a resolver, a DNSResolver of the target by default.
+func DefaultResolver() Resolver {
+	return defaultResolver.Load().(resolverHolder).Resolver
+}
+
+// SetDefaultResolver replace the resolver used by the discoveries created
+// afterwards without a resolver, it's safe to call concurrently.
+func SetDefaultResolver(r Resolver) {
+	defaultResolver.Store(resolverHolder{r})
+}
+
+// StaticResolver resolve targets from a fixed list of "ip:port" addresses.
+type StaticResolver struct {
+	services map[string][]*Address
+}
+
+func NewStaticResolver(services map[string][]string) (*StaticResolver, error) {
+	parsed, err := parseServices(services)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticResolver{services: parsed}, nil
+}
+
+func (r *StaticResolver) Resolve(ctx context.Context, target string) ([]*Address, error) {
+	addrs, ok := r.services[target]
+	if !ok || len(addrs) == 0 {
+		return nil, ErrNoAddress
+	}
+	return addrs, nil
+}
+
+// DNSResolver resolve targets by DNS SRV records.
+//
+// The record looked up is "_service._proto.target", or the target itself if
+// both service and proto are empty.
+type DNSResolver struct {
+	service string
+	proto   string
+}
+
+func NewDNSResolver(service, proto string) *DNSResolver {
+	return &DNSResolver{
+		service: service,
+		proto:   proto,
+	}
+}
+
+func (r *DNSResolver) Resolve(ctx context.Context, target string) ([]*Address, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, r.service, r.proto, target)
+	if err != nil {
+		return nil, errors.Wrap(err, "lookup srv")
+	}
+	if len(records) == 0 {
+		return nil, ErrNoAddress
+	}
+
+	addrs := make([]*Address, 0, len(records))
+	for _, record := range records {
+		addrs = append(addrs, &Address{
+			IP:   strings.TrimSuffix(record.Target, "."),
+			Port: strconv.Itoa(int(record.Port)),
+		})
+	}
+	return addrs, nil
+}
+
+// FileResolver resolve targets from a JSON or TOML file, which maps target
+// name to a list of "ip:port" addresses. eg.
+//
+//	content-thrift = ["10.0.0.1:9000", "10.0.0.2:9000"]
+//
+// The file is reloaded once its modification time changed, a broken file
+// keeps the last good list.
+type FileResolver struct {
+	path string
+
+	mu       sync.Mutex
+	modTime  time.Time
+	services map[string][]*Address
+}
+
+func NewFileResolver(path string) (*FileResolver, error) {
+	r := &FileResolver{path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *FileResolver) Resolve(ctx context.Context, target string) ([]*Address, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.reload(); err != nil && r.services == nil {
+		return nil, err
+	}
+
+	addrs, ok := r.services[target]
+	if !ok || len(addrs) == 0 {
+		return nil, ErrNoAddress
+	}
+	return addrs, nil
+}
+
+// reload parse the file if it has been modified since the last load.
+func (r *FileResolver) reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return errors.Wrap(err, "stat resolver file")
+	}
+	if r.services != nil && info.ModTime().Equal(r.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return errors.Wrap(err, "read resolver file")
+	}
+
+	raw := map[string][]string{}
+	switch strings.ToLower(filepath.Ext(r.path)) {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		err = fmt.Errorf("unsupported resolver file: %s", r.path)
+	}
+	if err != nil {
+		return errors.Wrap(err, "parse resolver file")
+	}
+
+	services, err := parseServices(raw)
+	if err != nil {
+		return err
+	}
+
+	r.services = services
+	r.modTime = info.ModTime()
+	return nil
+}
+
+func parseServices(raw map[string][]string) (map[string][]*Address, error) {
+	services := make(map[string][]*Address, len(raw))
+	for target, addrStrings := range raw {
+		addrs := make([]*Address, 0, len(addrStrings))
+		for _, s := range addrStrings {
+			addr, err := newAddressFromString(s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid address for %s", target)
+			}
+			addrs = append(addrs, addr)
+		}
+		services[target] = addrs
+	}
+	return services, nil
+}
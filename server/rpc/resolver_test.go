@@ -0,0 +1,270 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func addrStrings(addrs []*Address) []string {
+	s := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		s = append(s, addr.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+func TestStaticResolver(t *testing.T) {
+	r, err := NewStaticResolver(map[string][]string{
+		"svc":   {"10.0.0.1:9000", "10.0.0.2:9000"},
+		"empty": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		target string
+		want   string
+		err    error
+	}{
+		{"svc", "10.0.0.1:9000,10.0.0.2:9000", nil},
+		{"empty", "", ErrNoAddress},
+		{"missing", "", ErrNoAddress},
+	}
+	for _, tt := range tests {
+		addrs, err := r.Resolve(context.Background(), tt.target)
+		if got := strings.Join(addrStrings(addrs), ","); got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Resolve(%s) = %s, %v, want %s, %v", tt.target, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := NewStaticResolver(map[string][]string{"svc": {"10.0.0.1"}}); err == nil {
+		t.Error("NewStaticResolver with an address without port error = nil")
+	}
+}
+
+func TestFileResolver(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		invalid bool
+	}{
+		{"services.json", `{"svc": ["10.0.0.1:9000", "10.0.0.2:9000"]}`, "10.0.0.1:9000,10.0.0.2:9000", false},
+		{"services.toml", `svc = ["10.0.0.1:9000"]`, "10.0.0.1:9000", false},
+		{"services.yaml", `svc: ["10.0.0.1:9000"]`, "", true},
+		{"broken.json", `{"svc": [`, "", true},
+		{"port.toml", `svc = ["10.0.0.1"]`, "", true},
+		{"missing.json", "", "", true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if tt.content != "" {
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r, err := NewFileResolver(path)
+		if tt.invalid {
+			if err == nil {
+				t.Errorf("NewFileResolver(%s) error = nil", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewFileResolver(%s) error = %v", tt.name, err)
+			continue
+		}
+		addrs, err := r.Resolve(context.Background(), "svc")
+		if got := strings.Join(addrStrings(addrs), ","); got != tt.want || err != nil {
+			t.Errorf("%s: Resolve = %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+		if _, err := r.Resolve(context.Background(), "missing"); !errors.Is(err, ErrNoAddress) {
+			t.Errorf("%s: Resolve(missing) error = %v, want ErrNoAddress", tt.name, err)
+		}
+	}
+}
+
+func TestFileResolverReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.json")
+	modTime := time.Now()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// the modification time may not change in the same tick.
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"svc": ["10.0.0.1:9000"]}`)
+	r, err := NewFileResolver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"svc": ["10.0.0.2:9000"]}`, "10.0.0.2:9000"},
+		// a broken file keeps the last good list.
+		{`{"svc": [`, "10.0.0.2:9000"},
+		{`{"svc": ["10.0.0.3:9000", "10.0.0.4:9000"]}`, "10.0.0.3:9000,10.0.0.4:9000"},
+	}
+	for _, tt := range tests {
+		write(tt.content)
+		addrs, err := r.Resolve(context.Background(), "svc")
+		if got := strings.Join(addrStrings(addrs), ","); got != tt.want || err != nil {
+			t.Errorf("Resolve after %s = %s, %v, want %s", tt.content, got, err, tt.want)
+		}
+	}
+}
+
+// fakeDNS answer the SRV queries of name by records, and nothing else.
+func fakeDNS(t *testing.T, name string, records []*net.SRV) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := dnsResponse(buf[:n], name, records); resp != nil {
+				conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+
+	resolver := net.DefaultResolver
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+	t.Cleanup(func() {
+		net.DefaultResolver = resolver
+		conn.Close()
+	})
+}
+
+// dnsResponse build the response of a query with a single question.
+func dnsResponse(query []byte, name string, records []*net.SRV) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	// the question is the labels ended by a zero byte, then type and class.
+	end := 12
+	var labels []string
+	for end < len(query) && query[end] != 0 {
+		n := int(query[end])
+		if end+1+n > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[end+1:end+1+n]))
+		end += 1 + n
+	}
+	end += 5
+	if end > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	resp := append([]byte(nil), query[:end]...)
+	rcode := uint16(3) // NXDOMAIN
+	var answers []*net.SRV
+	if strings.EqualFold(strings.Join(labels, ".")+".", name) {
+		rcode = 0
+		if qtype == 33 {
+			answers = records
+		}
+	}
+	binary.BigEndian.PutUint16(resp[2:], 0x8180|rcode)
+	binary.BigEndian.PutUint16(resp[6:], uint16(len(answers)))
+	binary.BigEndian.PutUint16(resp[8:], 0)
+	binary.BigEndian.PutUint16(resp[10:], 0)
+
+	for _, srv := range answers {
+		var target []byte
+		for _, label := range strings.Split(strings.TrimSuffix(srv.Target, "."), ".") {
+			target = append(append(target, byte(len(label))), label...)
+		}
+		target = append(target, 0)
+
+		// the name points to the question.
+		resp = append(resp, 0xc0, 12)
+		resp = binary.BigEndian.AppendUint16(resp, 33)
+		resp = binary.BigEndian.AppendUint16(resp, 1)
+		resp = binary.BigEndian.AppendUint32(resp, 60)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(6+len(target)))
+		resp = binary.BigEndian.AppendUint16(resp, srv.Priority)
+		resp = binary.BigEndian.AppendUint16(resp, srv.Weight)
+		resp = binary.BigEndian.AppendUint16(resp, srv.Port)
+		resp = append(resp, target...)
+	}
+	return resp
+}
+
+func TestDNSResolver(t *testing.T) {
+	fakeDNS(t, "_thrift._tcp.svc.example.com.", []*net.SRV{
+		{Target: "host1.example.com.", Port: 9000, Priority: 10, Weight: 1},
+		{Target: "host2.example.com.", Port: 9001, Priority: 10, Weight: 1},
+	})
+
+	tests := []struct {
+		service, proto, target string
+		want                   string
+	}{
+		{"thrift", "tcp", "svc.example.com", "host1.example.com:9000,host2.example.com:9001"},
+		{"", "", "_thrift._tcp.svc.example.com", "host1.example.com:9000,host2.example.com:9001"},
+		{"thrift", "tcp", "missing.example.com", ""},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		addrs, err := NewDNSResolver(tt.service, tt.proto).Resolve(ctx, tt.target)
+		cancel()
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Resolve(%s) = %v, want error", tt.target, addrStrings(addrs))
+			}
+			continue
+		}
+		if got := strings.Join(addrStrings(addrs), ","); got != tt.want || err != nil {
+			t.Errorf("Resolve(%s) = %s, %v, want %s", tt.target, got, err, tt.want)
+		}
+	}
+}
+
+func TestSetDefaultResolver(t *testing.T) {
+	resolver := DefaultResolver()
+	defer SetDefaultResolver(resolver)
+
+	static, err := NewStaticResolver(map[string][]string{"svc": {"10.0.0.1:9000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefaultResolver(static)
+	d := NewDiscovery("svc")
+	if d.resolver != static {
+		t.Errorf("discovery resolver = %T, want the default set", d.resolver)
+	}
+	if addr, err := d.GetAddress(); err != nil || addr.String() != "10.0.0.1:9000" {
+		t.Errorf("GetAddress = %v, %v", addr, err)
+	}
+}
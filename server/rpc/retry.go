@@ -0,0 +1,120 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+
+	"github.com/YLeseclaireurs/icafe/breaker"
+	"github.com/YLeseclaireurs/icafe/utils"
+)
+
+// RetryPolicy control how the client retry a failed call.
+//
+// Only the transport errors of idempotent methods are retried, each attempt
+// is made against a different address if there is any, and the whole
+// sequence never outlives the deadline of the call context.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of attempts, including the first one.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the second attempt, it's multiplied
+	// by Multiplier for every next attempt and capped at MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Jitter randomize the backoff by ±Jitter of itself, should be in [0, 1].
+	Jitter float64
+
+	// IdempotentMethods is the thrift methods safe to be retried, eg. get_content.
+	IdempotentMethods []string
+
+	// HedgeDelay enable hedging if positive: fire another request to a
+	// different address when no response arrived after the delay, and take
+	// the first successful one. At most MaxHedged extra requests are fired
+	// for each attempt.
+	HedgeDelay time.Duration
+	MaxHedged  int
+}
+
+// DefaultRetryPolicy return a policy with 3 attempts and a backoff from 10ms to 100ms.
+func DefaultRetryPolicy(idempotentMethods ...string) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:       3,
+		InitialBackoff:    10 * time.Millisecond,
+		MaxBackoff:        100 * time.Millisecond,
+		Multiplier:        2,
+		Jitter:            0.2,
+		IdempotentMethods: idempotentMethods,
+	}
+}
+
+func (p *RetryPolicy) idempotent(method string) bool {
+	return utils.ContainStr(p.IdempotentMethods, method)
+}
+
+// backoff return the wait before the nth retry, n starts from 1.
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(math.Max(p.Multiplier, 1), float64(n-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// isRetryableError report whether the request may be sent again to another
+// address, the request rejected by an open breaker never left.
+func isRetryableError(err error) bool {
+	if errors.Is(err, breaker.ErrCircuitOpen) {
+		return true
+	}
+	var transportErr thrift.TTransportException
+	return errors.As(err, &transportErr)
+}
+
+// isBreakerFailure report whether the error means the address is unhealthy,
+// the requests canceled by caller, eg. the hedged losers, are not.
+func isBreakerFailure(err error) bool {
+	return isRetryableError(err) && !errors.Is(err, context.Canceled)
+}
+
+// sleepContext wait for d, return false if ctx is done or its deadline
+// comes before d passed.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// newResultOf create an empty result of the same type, so that concurrent
+// hedged attempts never decode into the same struct.
+func newResultOf(result thrift.TStruct) (thrift.TStruct, bool) {
+	v := reflect.ValueOf(result)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, false
+	}
+	r, ok := reflect.New(v.Elem().Type()).Interface().(thrift.TStruct)
+	return r, ok
+}
+
+func copyResult(dst, src thrift.TStruct) {
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(src).Elem())
+}
@@ -0,0 +1,219 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+
+	"github.com/YLeseclaireurs/icafe/breaker"
+	"github.com/YLeseclaireurs/icafe/example/gen-go/thrift/content_thrift/base"
+	"github.com/YLeseclaireurs/icafe/example/gen-go/thrift/content_thrift/content"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+		{4, 50 * time.Millisecond},
+		{10, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := p.backoff(tt.n); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+
+	// a multiplier below 1 never shrinks the backoff.
+	p.Multiplier = 0.5
+	if got := p.backoff(3); got != 10*time.Millisecond {
+		t.Errorf("backoff(3) with multiplier 0.5 = %s, want 10ms", got)
+	}
+
+	p.Multiplier, p.Jitter = 2, 0.2
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(2); got < 16*time.Millisecond || got > 24*time.Millisecond {
+			t.Fatalf("backoff(2) with jitter 0.2 = %s, want in [16ms, 24ms]", got)
+		}
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	transportErr := thrift.NewTTransportException(thrift.UNKNOWN_TRANSPORT_EXCEPTION, "connection refused")
+	canceled := thrift.NewTTransportExceptionFromError(context.Canceled)
+	tests := []struct {
+		name             string
+		err              error
+		retryable, fails bool
+	}{
+		{"transport", transportErr, true, true},
+		{"canceled", canceled, true, false},
+		{"circuit open", breaker.ErrCircuitOpen, true, true},
+		{"application", thrift.NewTApplicationException(thrift.INTERNAL_ERROR, "boom"), false, false},
+		{"other", errors.New("boom"), false, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryableError(tt.err); got != tt.retryable {
+			t.Errorf("%s: isRetryableError = %t, want %t", tt.name, got, tt.retryable)
+		}
+		if got := isBreakerFailure(tt.err); got != tt.fails {
+			t.Errorf("%s: isBreakerFailure = %t, want %t", tt.name, got, tt.fails)
+		}
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	short, cancelShort := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancelShort()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{"slept", context.Background(), true},
+		{"canceled", canceled, false},
+		{"deadline before", short, false},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		if got := sleepContext(tt.ctx, 10*time.Millisecond); got != tt.want {
+			t.Errorf("%s: sleepContext = %t, want %t", tt.name, got, tt.want)
+		}
+		if !tt.want && time.Since(start) >= 10*time.Millisecond {
+			t.Errorf("%s: sleepContext waited %s", tt.name, time.Since(start))
+		}
+	}
+}
+
+func TestNewResultOfCopyResult(t *testing.T) {
+	result := content.NewContentServiceGetContentResult()
+	r, ok := newResultOf(result)
+	if !ok {
+		t.Fatalf("newResultOf(%T) not ok", result)
+	}
+	hedged, ok := r.(*content.ContentServiceGetContentResult)
+	if !ok || hedged == result || hedged.Success != nil {
+		t.Fatalf("newResultOf = %#v, want a new empty result", r)
+	}
+
+	hedged.Success = &content.GetContentResponse{Content: &base.Content{ID: 1}}
+	copyResult(result, hedged)
+	if result.GetSuccess().GetContent().GetID() != 1 {
+		t.Errorf("copied result = %#v", result)
+	}
+}
+
+// testContentService reply the content of its id after delay, or fail
+// with status if not 0.
+type testContentService struct {
+	id     int64
+	delay  time.Duration
+	status int
+	calls  atomic.Int32
+}
+
+func (s *testContentService) GetContent(ctx context.Context, param *content.GetContentParam) (*content.GetContentResponse, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &content.GetContentResponse{Content: &base.Content{ID: s.id}}, nil
+}
+
+func (s *testContentService) start(t *testing.T) string {
+	server := NewServer(map[string]thrift.TProcessor{
+		"ContentService": content.NewContentServiceProcessor(s),
+	})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			return
+		}
+		server.Handler(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return strings.TrimPrefix(ts.URL, "http://")
+}
+
+func TestClientRetry(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:       3,
+		InitialBackoff:    time.Millisecond,
+		Multiplier:        2,
+		IdempotentMethods: []string{"get_content"},
+	}
+	hedging := policy
+	hedging.HedgeDelay = 10 * time.Millisecond
+	hedging.MaxHedged = 1
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		services []*testContentService
+		wantID   int64
+		// the calls received by each service.
+		wantCalls []int32
+	}{
+		{"retried to another address", policy,
+			[]*testContentService{{id: 1}, {id: 2, status: http.StatusServiceUnavailable}}, 1, []int32{1, 1}},
+		{"not idempotent", RetryPolicy{MaxAttempts: 3, IdempotentMethods: []string{"other"}},
+			[]*testContentService{{id: 1}, {id: 2, status: http.StatusServiceUnavailable}}, 0, []int32{0, 1}},
+		{"attempts exhausted", policy,
+			[]*testContentService{{id: 1, status: http.StatusBadGateway}, {id: 2, status: http.StatusServiceUnavailable}}, 0, nil},
+		{"hedged", hedging,
+			[]*testContentService{{id: 1}, {id: 2, delay: time.Second}}, 1, []int32{1, 1}},
+	}
+	for _, tt := range tests {
+		services := map[string][]string{}
+		for _, s := range tt.services {
+			services["content"] = append(services["content"], s.start(t))
+		}
+		resolver, err := NewStaticResolver(services)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the first address picked by round-robin is the second one.
+		client := content.NewContentServiceClient(New("ContentService", TargetName("content"),
+			Retry(tt.policy), DiscoveryOptions(DiscoveryResolver(resolver))))
+
+		start := time.Now()
+		resp, err := client.GetContent(context.Background(), &content.GetContentParam{ContentID: 1})
+		if tt.wantID == 0 {
+			if err == nil {
+				t.Errorf("%s: GetContent = %v, want error", tt.name, resp)
+			}
+		} else if err != nil || resp.GetContent().GetID() != tt.wantID {
+			t.Errorf("%s: GetContent = %v, %v, want content %d", tt.name, resp, err, tt.wantID)
+		}
+		if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+			t.Errorf("%s: GetContent took %s", tt.name, elapsed)
+		}
+
+		var total int32
+		for i, s := range tt.services {
+			total += s.calls.Load()
+			if tt.wantCalls != nil && s.calls.Load() != tt.wantCalls[i] {
+				t.Errorf("%s: service %d called %d times, want %d", tt.name, s.id, s.calls.Load(), tt.wantCalls[i])
+			}
+		}
+		if tt.wantCalls == nil && total != int32(tt.policy.MaxAttempts) {
+			t.Errorf("%s: called %d times, want %d attempts", tt.name, total, tt.policy.MaxAttempts)
+		}
+	}
+}
@@ -2,10 +2,12 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/YLeseclaireurs/icafe/log"
 	"github.com/YLeseclaireurs/icafe/server"
 	"net/http"
+	"time"
 )
 
 type TRPCBundle struct {
@@ -15,13 +17,18 @@ type TRPCBundle struct {
 	serviceMap       map[string]thrift.TProcessor
 	listenAddr       string
 	extraMiddlewares []func(http.Handler) http.Handler
+
+	drainDelay   time.Duration
+	drainTimeout time.Duration
 }
 
 func NewTRPCBundle(name string, opts ...TRPCOption) server.Bundle {
 	defaults := getDefaults()
 	s := &TRPCBundle{
-		name:       name,
-		listenAddr: defaults.listenAddr,
+		name:         name,
+		listenAddr:   defaults.listenAddr,
+		drainDelay:   defaults.drainDelay,
+		drainTimeout: defaults.drainTimeout,
 	}
 
 	for _, opt := range opts {
@@ -42,21 +49,44 @@ func (s *TRPCBundle) Name() string {
 	return s.name
 }
 
+func (s *TRPCBundle) ListenAddr() string {
+	return s.listenAddr
+}
+
+// Ready is closed once the bundle is listening.
+func (s *TRPCBundle) Ready() <-chan struct{} {
+	return s.server.Ready()
+}
+
 func (s *TRPCBundle) Run(ctx context.Context) error {
-	return s.server.Run(s.listenAddr)
+	err := s.server.Run(s.listenAddr)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
+// Stop fail the health check first, wait the drain delay for the load
+// balancers to notice, then wait the in-flight requests until the drain
+// timeout, after which they are aborted. The context is done once drained.
 func (s *TRPCBundle) Stop() context.Context {
-	ctx2, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx, cancel := context.WithCancel(context.Background())
 
 	go func() {
-		err := s.server.Close()
-		if err != nil {
-			log.Errorf("Close tzone service error: %v", err)
+		defer cancel()
+
+		s.server.Drain()
+		time.Sleep(s.drainDelay)
+
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), s.drainTimeout)
+		defer cancelShutdown()
+		if err := s.server.Shutdown(shutdownCtx); err != nil {
+			log.Warnf("Drain tzone[%s] error: %v, force closing", s.name, err)
+			if err := s.server.Close(); err != nil {
+				log.Errorf("Close tzone service error: %v", err)
+			}
 		}
-		ctx2.Done()
 	}()
 
-	return ctx2
+	return ctx
 }
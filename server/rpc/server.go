@@ -1,7 +1,11 @@
 package rpc
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
@@ -9,8 +13,10 @@ import (
 
 func NewServer(services map[string]thrift.TProcessor) *Server {
 	s := &Server{
+		ready:           make(chan struct{}),
 		processor:       thrift.NewTMultiplexedProcessor(),
 		protocolFactory: thrift.NewTBinaryProtocolFactoryDefault(),
+		services:        services,
 	}
 	for serviceName, serviceProcessor := range services {
 		s.processor.RegisterProcessor(serviceName, serviceProcessor)
@@ -20,9 +26,14 @@ func NewServer(services map[string]thrift.TProcessor) *Server {
 }
 
 type Server struct {
+	mu              sync.Mutex
 	httpServer      *http.Server
+	draining        atomic.Bool
+	ready           chan struct{}
+	readyOnce       sync.Once
 	processor       *thrift.TMultiplexedProcessor
 	protocolFactory *thrift.TBinaryProtocolFactory
+	services        map[string]thrift.TProcessor
 	middlewares     []func(http.Handler) http.Handler
 }
 
@@ -42,20 +53,68 @@ func (s *Server) Run(addr string) error {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/check_health", func(w http.ResponseWriter, _ *http.Request) {
+		if s.draining.Load() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
 		_, _ = w.Write([]byte("zhi~"))
 	})
-	//mux.Handle("/", s.Chain(http.HandlerFunc(s.Handler)))
+	mux.Handle("/", serverTracing(serverMetrics(s.Chain(http.HandlerFunc(s.Handler)))))
 
-	s.httpServer = &http.Server{
+	httpServer := &http.Server{
 		Addr:           addr,
 		Handler:        mux,
 		ReadTimeout:    5 * time.Second,
 		WriteTimeout:   5 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 	}
-	return s.httpServer.ListenAndServe()
+	s.mu.Lock()
+	s.httpServer = httpServer
+	s.mu.Unlock()
+	// shut down before serving.
+	if s.draining.Load() {
+		return http.ErrServerClosed
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.readyOnce.Do(func() {
+		close(s.ready)
+	})
+	return httpServer.Serve(ln)
+}
+
+// Ready is closed once the server is listening.
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Drain fail the health check, so the load balancers stop sending new requests.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// Shutdown stop accepting and wait for the in-flight requests until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Drain()
+	if httpServer := s.getHTTPServer(); httpServer != nil {
+		return httpServer.Shutdown(ctx)
+	}
+	return nil
 }
 
+// Close abort the in-flight requests.
 func (s *Server) Close() error {
-	return s.httpServer.Close()
+	if httpServer := s.getHTTPServer(); httpServer != nil {
+		return httpServer.Close()
+	}
+	return nil
+}
+
+func (s *Server) getHTTPServer() *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.httpServer
 }
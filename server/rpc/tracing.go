@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/YLeseclaireurs/icafe/tracing"
+)
+
+// responseWriter record the status written to the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.status = status
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// serverTracing continue the trace from the request headers, and carry the
+// server span in the request context.
+func serverTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parent, _ := tracing.Extract(tracing.HeaderCarrier(r.Header))
+		ctx, span := tracing.StartSpanWithParent(r.Context(), parent, apiName(r), tracing.KindServer)
+		span.SetAttribute("peer", r.RemoteAddr)
+		if origin := r.Header.Get("X-ZONE-ORIGIN-APP"); origin != "" {
+			span.SetAttribute("origin", origin)
+		}
+
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		var err error
+		if rw.status >= http.StatusBadRequest {
+			err = fmt.Errorf("http status %d", rw.status)
+		}
+		span.Finish(err)
+	})
+}
+
+// apiName return the called "service.method" sent by client, or the path.
+func apiName(r *http.Request) string {
+	if api := r.Header.Get("X-ZONE-API"); api != "" {
+		return api
+	}
+	return r.URL.Path
+}
@@ -7,24 +7,36 @@ import (
 
 	"github.com/apache/thrift/lib/go/thrift"
 	"github.com/sirupsen/logrus"
+
+	"github.com/YLeseclaireurs/icafe/log"
+	"github.com/YLeseclaireurs/icafe/tracing"
 )
 
 const (
 	defaultBufferSize = 4096
 )
 
-func newTransport(ctx context.Context, client *http.Client, targetURL string, serviceName, method string, headers map[string]string) thrift.TTransport {
+func newTransport(ctx context.Context, client *http.Client, targetURL string, serviceName, method, origin string, headers map[string]string) thrift.TTransport {
 	customHeaders := http.Header{
 		"X-ZONE-API":        []string{serviceName + "." + method},
-		"X-ZONE-ORIGIN":     []string{"ZvideoService"},
-		"X-ZONE-ORIGIN-APP": []string{"zvideo"},
+		"X-ZONE-ORIGIN":     []string{origin},
+		"X-ZONE-ORIGIN-APP": []string{origin},
 	}
 
-	for k, v := range headers {
-		if strings.HasPrefix(k, "X-ZONE") {
-			continue
+	for _, h := range []map[string]string{headers, OutgoingHeaders(ctx)} {
+		for k, v := range h {
+			if strings.HasPrefix(k, "X-ZONE") {
+				continue
+			}
+			customHeaders[k] = []string{v}
 		}
-		customHeaders[k] = []string{v}
+	}
+
+	if requestID := log.RequestIDFromContext(ctx); requestID != "" {
+		customHeaders[RequestIDHeader] = []string{requestID}
+	}
+	if sc, ok := tracing.SpanContextFromContext(ctx); ok {
+		tracing.Inject(sc, tracing.HeaderCarrier(customHeaders))
 	}
 
 	transport, err := thrift.NewTHttpClientWithOptions(targetURL, thrift.THttpClientOptions{Client: client})
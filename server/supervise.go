@@ -0,0 +1,75 @@
+package server
+
+import (
+	"time"
+)
+
+type policyKind int
+
+const (
+	policyIgnore policyKind = iota
+	policyShutdown
+	policyRestart
+)
+
+// FailurePolicy decide what happens when Run of a bundle returns an error,
+// see Container.Supervise.
+type FailurePolicy struct {
+	kind        policyKind
+	maxRestarts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// IgnoreFailure log the error and keep the other bundles running.
+func IgnoreFailure() FailurePolicy {
+	return FailurePolicy{kind: policyIgnore}
+}
+
+// ShutdownOnFailure shut down the application, whose Run returns a non-zero
+// exit code.
+func ShutdownOnFailure() FailurePolicy {
+	return FailurePolicy{kind: policyShutdown}
+}
+
+// RestartOnFailure run the bundle again after backoff, which is doubled on
+// every restart up to maxBackoff. The application is shut down once the
+// bundle failed after maxRestarts restarts, 0 restarts forever.
+func RestartOnFailure(maxRestarts int, backoff, maxBackoff time.Duration) FailurePolicy {
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+	return FailurePolicy{
+		kind:        policyRestart,
+		maxRestarts: maxRestarts,
+		backoff:     backoff,
+		maxBackoff:  maxBackoff,
+	}
+}
+
+func (p FailurePolicy) String() string {
+	switch p.kind {
+	case policyShutdown:
+		return "shutdown"
+	case policyRestart:
+		return "restart"
+	}
+	return "ignore"
+}
+
+// delay return the backoff before the n-th restart, from 1.
+func (p FailurePolicy) delay(n int) time.Duration {
+	d := p.backoff
+	for i := 1; i < n && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	return d
+}
+
+// exhausted return whether no more restart after restarts.
+func (p FailurePolicy) exhausted(restarts int) bool {
+	return p.maxRestarts > 0 && restarts >= p.maxRestarts
+}
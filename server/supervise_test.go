@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFailurePolicyDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy FailurePolicy
+		delays []time.Duration
+	}{
+		{"doubled up to max", RestartOnFailure(0, 10*time.Millisecond, 50*time.Millisecond),
+			[]time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}},
+		{"max below backoff", RestartOnFailure(0, 10*time.Millisecond, time.Millisecond),
+			[]time.Duration{10 * time.Millisecond, 10 * time.Millisecond}},
+		{"no backoff", RestartOnFailure(0, 0, 0),
+			[]time.Duration{0, 0}},
+	}
+	for _, tt := range tests {
+		for i, want := range tt.delays {
+			if got := tt.policy.delay(i + 1); got != want {
+				t.Errorf("%s: delay(%d) = %s, want %s", tt.name, i+1, got, want)
+			}
+		}
+	}
+}
+
+func TestFailurePolicyExhausted(t *testing.T) {
+	tests := []struct {
+		policy   FailurePolicy
+		restarts int
+		want     bool
+	}{
+		{RestartOnFailure(3, time.Millisecond, time.Millisecond), 2, false},
+		{RestartOnFailure(3, time.Millisecond, time.Millisecond), 3, true},
+		{RestartOnFailure(0, time.Millisecond, time.Millisecond), 1000, false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.exhausted(tt.restarts); got != tt.want {
+			t.Errorf("exhausted(%d) of max %d = %t, want %t", tt.restarts, tt.policy.maxRestarts, got, tt.want)
+		}
+	}
+}
+
+// flakyBundle fail the first failures runs, then runs until stopped.
+type flakyBundle struct {
+	name     string
+	failures int
+
+	mu     sync.Mutex
+	starts []time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newFlakyBundle(name string, failures int) *flakyBundle {
+	return &flakyBundle{name: name, failures: failures, stop: make(chan struct{})}
+}
+
+func (b *flakyBundle) Type() string { return "test" }
+
+func (b *flakyBundle) Name() string { return b.name }
+
+func (b *flakyBundle) Run(ctx context.Context) error {
+	b.mu.Lock()
+	b.starts = append(b.starts, time.Now())
+	n := len(b.starts)
+	b.mu.Unlock()
+	if b.failures < 0 || n <= b.failures {
+		return errors.New("boom")
+	}
+	select {
+	case <-b.stop:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func (b *flakyBundle) Stop() context.Context {
+	b.stopOnce.Do(func() { close(b.stop) })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func (b *flakyBundle) runs() []time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]time.Time(nil), b.starts...)
+}
+
+func statusOf(c *Container, name string) BundleStatus {
+	for _, st := range c.BundleStatuses() {
+		if st.Name == name {
+			return st
+		}
+	}
+	return BundleStatus{}
+}
+
+func TestContainerRestartBackoff(t *testing.T) {
+	bundle := newFlakyBundle("flaky", 3)
+	policy := RestartOnFailure(0, 20*time.Millisecond, 40*time.Millisecond)
+	c := New()
+	c.AddBundle(bundle)
+	if err := c.Supervise(bundle, policy); err != nil {
+		t.Fatal(err)
+	}
+
+	c.StartAll(context.Background())
+	defer c.StopAll(context.Background())
+	waitUntil(t, "running after restarts", func() bool {
+		return len(bundle.runs()) >= 4 && statusOf(c, "flaky").State == BundleRunning
+	})
+
+	runs := bundle.runs()
+	if len(runs) != 4 {
+		t.Fatalf("%d runs, want 3 failures and a run", len(runs))
+	}
+	for i := 1; i < len(runs); i++ {
+		if gap, want := runs[i].Sub(runs[i-1]), policy.delay(i); gap < want {
+			t.Errorf("restart %d after %s, want the backoff %s", i, gap, want)
+		}
+	}
+	if st := statusOf(c, "flaky"); st.Restarts != 3 {
+		t.Errorf("%d restarts, want 3", st.Restarts)
+	}
+}
+
+func TestContainerFailurePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  FailurePolicy
+		runs    int
+		failure string
+	}{
+		{"ignore", IgnoreFailure(), 1, ""},
+		{"shutdown", ShutdownOnFailure(), 1, "bundle test[flaky] failed: boom"},
+		{"restarts exhausted", RestartOnFailure(2, time.Millisecond, time.Millisecond), 3,
+			"bundle test[flaky] failed after 2 restarts: boom"},
+	}
+	for _, tt := range tests {
+		bundle := newFlakyBundle("flaky", -1)
+		other := newFlakyBundle("other", 0)
+		c := New()
+		c.AddBundle(bundle, other)
+		c.SetDefaultPolicy(tt.policy)
+
+		c.StartAll(context.Background())
+		waitUntil(t, "the bundle failed", func() bool { return statusOf(c, "flaky").State == BundleFailed })
+
+		if tt.failure == "" {
+			select {
+			case <-c.Failed():
+				t.Errorf("%s: failed by %v", tt.name, c.Failure())
+			case <-time.After(20 * time.Millisecond):
+			}
+			if st := statusOf(c, "other"); st.State != BundleRunning {
+				t.Errorf("%s: the other bundle %s, want running", tt.name, st.State)
+			}
+		} else {
+			select {
+			case <-c.Failed():
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: not failed", tt.name)
+			}
+			if err := c.Failure(); err == nil || !strings.Contains(err.Error(), tt.failure) {
+				t.Errorf("%s: Failure = %v, want %q", tt.name, err, tt.failure)
+			}
+		}
+		if n := len(bundle.runs()); n != tt.runs {
+			t.Errorf("%s: %d runs, want %d", tt.name, n, tt.runs)
+		}
+		<-c.StopAll(context.Background()).Done()
+	}
+}
@@ -0,0 +1,48 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/YLeseclaireurs/icafe/tomlconfig"
+)
+
+func init() {
+	tomlconfig.RegisterValidator("mysql_dsn", func(v reflect.Value, _ string) error {
+		return tomlconfig.EachString(v, func(dsn string) error {
+			if _, err := mysql.ParseDSN(dsn); err != nil {
+				return fmt.Errorf("invalid mysql dsn: %v", err)
+			}
+			return nil
+		})
+	})
+}
+
+// section is the config section of a Group, eg.
+//
+//	[[database]]
+//	name = "test1"
+//	master = "user:password@tcp(127.0.0.1:3306)/test?charset=utf8"
+//	slaves = ["user:password@tcp(127.0.0.1:3307)/test?charset=utf8"]
+type section struct {
+	Master string   `toml:"master" validate:"required,mysql_dsn"`
+	Slaves []string `toml:"slaves" validate:"mysql_dsn"`
+}
+
+// NewGroupFromConfig create a Group from the config section, eg.
+// app.Config().Sub("database.test1").
+func NewGroupFromConfig(name string, conf *tomlconfig.Config) (*Group, error) {
+	var s section
+	if err := conf.Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode mysql [%s] config error %s", name, err)
+	}
+	g, err := NewGroup(name, s.Master, s.Slaves)
+	if err != nil {
+		// the error has the dsn, which may have a resolved password.
+		return nil, errors.New(conf.Redact(err.Error()))
+	}
+	return g, nil
+}
@@ -0,0 +1,47 @@
+package sql
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YLeseclaireurs/icafe/tomlconfig"
+)
+
+func TestNewGroupFromConfigStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	data := `
+[[database]]
+name = "test1"
+master = "user:password@tcp(127.0.0.1:1)/test?charset=utf8&timeout=100ms"
+
+[[database]]
+name = "test2"
+master = "user:password@tcp(127.0.0.1:1)/test?charset=utf8&timeout=100ms"
+typo = "x"
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := tomlconfig.Load(tomlconfig.File(path), tomlconfig.WithEnvPrefix(""), tomlconfig.Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the section decodes, the group then fails to reach the unused port.
+	_, err = NewGroupFromConfig("test1", conf.Sub("database.test1"))
+	if err == nil || !strings.HasPrefix(err.Error(), "open mysql [test1]") {
+		t.Errorf("NewGroupFromConfig(test1) error = %v, want open mysql error", err)
+	}
+
+	_, err = NewGroupFromConfig("test2", conf.Sub("database.test2"))
+	if err == nil || !strings.Contains(err.Error(), "database.test2.typo: unknown key") {
+		t.Errorf("NewGroupFromConfig(test2) error = %v, want unknown key typo", err)
+	}
+
+	_, err = NewGroupFromConfig("test1", conf.Sub("database.0"))
+	if err == nil || !strings.HasPrefix(err.Error(), "open mysql [test1]") {
+		t.Errorf("NewGroupFromConfig(0) error = %v, want open mysql error", err)
+	}
+}
@@ -26,6 +26,7 @@ func NewGroup(name string, master string, slaves []string) (*Group, error) {
 		return nil, fmt.Errorf("open mysql [%s] master %s error %s", name, master, err)
 	}
 	db = db.Debug()
+	instrument(db, name)
 
 	g := Group{
 		name:    name,
@@ -39,6 +40,7 @@ func NewGroup(name string, master string, slaves []string) (*Group, error) {
 			return nil, fmt.Errorf("open mysql [%s] slave at %s error %s", name, slave, err)
 		}
 		db = db.Debug()
+		instrument(db, name)
 		g.replica = append(g.replica, &Client{
 			DB: db,
 		})
@@ -0,0 +1,69 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/YLeseclaireurs/icafe/metrics"
+)
+
+const startTimeKey = "icafe:start_time"
+
+var queryDurationSeconds = metrics.NewHistogram(
+	"sql_query_duration_seconds",
+	"Latency of sql queries.",
+	[]float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	"group", "operation", "status",
+)
+
+// instrument observe the latency of each query made by db, from before the
+// first gorm callback of the operation to after the last one.
+func instrument(db *gorm.DB, group string) {
+	callback := db.Callback()
+	// a processor is registered once, so each registration gets a new one.
+	processors := map[string]func() *gorm.CallbackProcessor{
+		"create": callback.Create,
+		"query":  callback.Query,
+		"update": callback.Update,
+		"delete": callback.Delete,
+		"row":    callback.RowQuery,
+	}
+	firstCallbacks := map[string]string{
+		"create": "gorm:begin_transaction",
+		"query":  "gorm:query",
+		"update": "gorm:begin_transaction",
+		"delete": "gorm:begin_transaction",
+		"row":    "gorm:row_query",
+	}
+	lastCallbacks := map[string]string{
+		"create": "gorm:commit_or_rollback_transaction",
+		"query":  "gorm:after_query",
+		"update": "gorm:commit_or_rollback_transaction",
+		"delete": "gorm:commit_or_rollback_transaction",
+		"row":    "gorm:row_query",
+	}
+
+	for operation, processor := range processors {
+		op := operation
+		processor().Before(firstCallbacks[op]).Register("icafe:before_"+op, func(scope *gorm.Scope) {
+			scope.InstanceSet(startTimeKey, time.Now())
+		})
+		processor().After(lastCallbacks[op]).Register("icafe:after_"+op, func(scope *gorm.Scope) {
+			v, ok := scope.InstanceGet(startTimeKey)
+			if !ok {
+				return
+			}
+			start, ok := v.(time.Time)
+			if !ok {
+				return
+			}
+
+			status := "ok"
+			if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+				status = "error"
+			}
+			queryDurationSeconds.ObserveDuration(time.Since(start), group, op, status)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+
+	"github.com/YLeseclaireurs/icafe/metrics"
+)
+
+type metricsUser struct {
+	ID   int
+	Name string
+}
+
+// queryCount return the count of sql_query_duration_seconds of the labels.
+func queryCount(t *testing.T, group, operation, status string) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := metrics.DefaultRegistry.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	prefix := `sql_query_duration_seconds_count{group="` + group + `",operation="` + operation + `",status="` + status + `"} `
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			n, err := strconv.Atoi(strings.TrimPrefix(line, prefix))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+func TestInstrument(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	instrument(db, "metrics_test")
+
+	if err := db.CreateTable(&metricsUser{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		operation, status string
+		run               func() error
+	}{
+		{"create", "ok", func() error { return db.Create(&metricsUser{ID: 1, Name: "a"}).Error }},
+		{"query", "ok", func() error { return db.First(&metricsUser{}, 1).Error }},
+		// not found is not an error of the database.
+		{"query", "ok", func() error {
+			if err := db.First(&metricsUser{}, 2).Error; !gorm.IsRecordNotFoundError(err) {
+				return err
+			}
+			return nil
+		}},
+		{"query", "error", func() error {
+			if db.Table("missing").Find(&[]metricsUser{}).Error == nil {
+				t.Error("query missing table error = nil")
+			}
+			return nil
+		}},
+		{"update", "ok", func() error { return db.Model(&metricsUser{ID: 1}).Update("name", "b").Error }},
+		{"row", "ok", func() error { return db.Raw("SELECT 1").Row().Scan(new(int)) }},
+		{"delete", "ok", func() error { return db.Delete(&metricsUser{ID: 1}).Error }},
+	}
+	for _, tt := range tests {
+		before := queryCount(t, "metrics_test", tt.operation, tt.status)
+		if err := tt.run(); err != nil {
+			t.Fatalf("%s error = %v", tt.operation, err)
+		}
+		if got := queryCount(t, "metrics_test", tt.operation, tt.status); got != before+1 {
+			t.Errorf("%s %s count = %d, want %d", tt.operation, tt.status, got, before+1)
+		}
+	}
+}
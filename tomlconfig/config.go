@@ -1,10 +1,379 @@
 package tomlconfig
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
 )
 
-func ParseTomlConfig(filepath string, v interface{}) error {
-	_, err := toml.DecodeFile(filepath, v)
+// ParseTomlConfig decode the file into v, the fields are filled by the
+// default tags and validated by the validate tags, see check.
+func ParseTomlConfig(filepath string, v interface{}, opts ...Option) error {
+	l := defaultLoader()
+	for _, o := range opts {
+		o(l)
+	}
+	c := &Config{tree: map[string]interface{}{}, strict: l.strict, secrets: newSecrets()}
+	if err := c.mergeFile(filepath); err != nil {
+		return err
+	}
+	if err := c.resolve(); err != nil {
+		return err
+	}
+	return c.Decode(v)
+}
+
+// Config is a config tree merged from layers, later layers override earlier:
+//
+//  1. the base file, eg. conf/config.toml
+//  2. the overlay file of the environment, eg. conf/config.prod.toml
+//  3. the environment variables prefixed with ICAFE_, in which "__" separates
+//     the keys, eg. ICAFE_REDIS__MAIN__MAX_IDLE=20 set redis.main.max_idle
+//
+// A key is a dotted path, eg. "redis.main". An element of an array of tables
+// is selected by its index or by its "name" key, eg. "database.test1".
+//
+// The secret references in the string values, eg. ${env:DB_PASSWORD},
+// ${file:/run/secrets/db} or ${vault:secret/data/db#password}, are resolved
+// by the SecretProviders, and redacted when the config is dumped or printed.
+type Config struct {
+	tree    map[string]interface{}
+	files   []string
+	secrets *secrets
+
+	// prefix is the key of the section, strict is whether the undecoded keys are errors.
+	prefix []string
+	strict bool
+	// element is whether the section is an element of an array of tables,
+	// whose "name" selector key is not reported in strict mode.
+	element bool
+}
+
+// Load read the layered config by the options.
+func Load(opts ...Option) (*Config, error) {
+	l := defaultLoader()
+	for _, o := range opts {
+		o(l)
+	}
+	return l.load()
+}
+
+// Parse create a config from toml content, mostly for testing.
+func Parse(data string) (*Config, error) {
+	tree := map[string]interface{}{}
+	if _, err := toml.Decode(data, &tree); err != nil {
+		return nil, err
+	}
+	c := &Config{tree: tree, secrets: newSecrets()}
+	if err := c.resolve(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (l *loader) load() (*Config, error) {
+	c := &Config{tree: map[string]interface{}{}, strict: l.strict, secrets: newSecrets()}
+
+	if err := c.mergeFile(l.file); err != nil {
+		return nil, err
+	}
+	if overlay := l.overlayFile(); overlay != "" {
+		if err := c.mergeFile(overlay); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if l.envPrefix != "" {
+		if err := c.mergeEnv(l.envPrefix, l.environ()); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.resolve(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// resolve the secret references with a timeout.
+func (c *Config) resolve() error {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	return c.resolveSecrets(ctx)
+}
+
+// overlayFile return the overlay file of the environment, empty if no environment.
+func (l *loader) overlayFile() string {
+	if l.env == "" {
+		return ""
+	}
+	ext := filepath.Ext(l.file)
+	return strings.TrimSuffix(l.file, ext) + "." + l.env + ext
+}
+
+func (c *Config) mergeFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tree := map[string]interface{}{}
+	if _, err := toml.Decode(string(data), &tree); err != nil {
+		return errors.Wrapf(err, "parse config %s", path)
+	}
+	merge(c.tree, tree)
+	c.files = append(c.files, path)
+	return nil
+}
+
+// mergeEnv set the keys by the environment variables with prefix.
+func (c *Config) mergeEnv(prefix string, environ []string) error {
+	sort.Strings(environ)
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || name == envConfigFile || name == envName {
+			continue
+		}
+		path := strings.Split(strings.TrimPrefix(name, prefix), "__")
+		if err := set(c.tree, path, parseValue(value)); err != nil {
+			return errors.Wrapf(err, "override config by %s", name)
+		}
+	}
+	return nil
+}
+
+// Files return the files loaded, in order.
+func (c *Config) Files() []string {
+	if c == nil {
+		return nil
+	}
+	return c.files
+}
+
+// Get return the value of key.
+func (c *Config) Get(key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	if key == "" {
+		return c.tree, true
+	}
+	var node interface{} = c.tree
+	for _, k := range strings.Split(key, ".") {
+		next, ok := child(node, k)
+		if !ok {
+			return nil, false
+		}
+		node = next
+	}
+	return node, true
+}
+
+func (c *Config) Has(key string) bool {
+	_, ok := c.Get(key)
+	return ok
+}
+
+// String return the value of key as string, empty if not found.
+func (c *Config) String(key string) string {
+	v, ok := c.Get(key)
+	if !ok {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// Sub return the section of key, nil if not found or not a table.
+func (c *Config) Sub(key string) *Config {
+	v, ok := c.Get(key)
+	if !ok {
+		return nil
+	}
+	tree, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := strings.Split(key, ".")
+	return &Config{
+		tree:    tree,
+		files:   c.files,
+		secrets: c.secrets,
+		prefix:  append(append([]string(nil), c.prefix...), keys...),
+		strict:  c.strict,
+		element: c.isElement(keys),
+	}
+}
+
+// isElement return whether the last key selects an element of an array of
+// tables, by index or by name.
+func (c *Config) isElement(keys []string) bool {
+	parent, ok := c.Get(strings.Join(keys[:len(keys)-1], "."))
+	if !ok {
+		return false
+	}
+	_, ok = parent.([]map[string]interface{})
+	return ok
+}
+
+// Keys return the sorted keys of the top level.
+func (c *Config) Keys() []string {
+	if c == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(c.tree))
+	for k := range c.tree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Decode the whole config into v by the toml tags, the fields are filled by
+// the default tags and validated by the validate tags, all the problems are
+// returned at once as ValidationErrors.
+func (c *Config) Decode(v interface{}) error {
+	md, err := c.decode(v)
+	if err != nil {
+		return errors.New(c.Redact(err.Error()))
+	}
+	var tree map[string]interface{}
+	var prefix []string
+	var strict, element bool
+	if c != nil {
+		tree, prefix, strict, element = c.tree, c.prefix, c.strict, c.element
+	}
+	err = check(v, md, tree, prefix, strict, element)
+	if errs, ok := err.(ValidationErrors); ok {
+		for _, fe := range errs {
+			fe.Message = c.Redact(fe.Message)
+		}
+	}
 	return err
 }
+
+// DecodeKey decode the section of key into v, a missing section is decoded
+// as an empty one, so the defaults and the rules still apply.
+func (c *Config) DecodeKey(key string, v interface{}) error {
+	sub := c.Sub(key)
+	if sub == nil {
+		if c.Has(key) {
+			return fmt.Errorf("config %s is not a table", key)
+		}
+		sub = &Config{tree: map[string]interface{}{}, strict: c != nil && c.strict}
+		if c != nil {
+			sub.secrets = c.secrets
+			sub.prefix = append(append([]string(nil), c.prefix...), strings.Split(key, ".")...)
+		}
+	}
+	return sub.Decode(v)
+}
+
+func (c *Config) decode(v interface{}) (toml.MetaData, error) {
+	var buf bytes.Buffer
+	if c != nil {
+		if err := toml.NewEncoder(&buf).Encode(c.tree); err != nil {
+			return toml.MetaData{}, errors.Wrap(err, "encode config")
+		}
+	}
+	return toml.Decode(buf.String(), v)
+}
+
+// child return the child of node by key, the element of an array of tables
+// is selected by index or by name, the name is matched case-insensitively.
+func child(node interface{}, key string) (interface{}, bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		v, ok := n[key]
+		return v, ok
+	case []map[string]interface{}:
+		if i, err := strconv.Atoi(key); err == nil {
+			if i < 0 || i >= len(n) {
+				return nil, false
+			}
+			return n[i], true
+		}
+		for _, t := range n {
+			if name, ok := t["name"].(string); ok && strings.EqualFold(name, key) {
+				return t, true
+			}
+		}
+	case []interface{}:
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(n) {
+			return n[i], true
+		}
+	}
+	return nil, false
+}
+
+// set the value at path, the keys of tables are matched case-insensitively,
+// the missing tables are created with lower case keys.
+func set(tree map[string]interface{}, path []string, value interface{}) error {
+	var node interface{} = tree
+	for i, key := range path {
+		last := i == len(path)-1
+
+		switch n := node.(type) {
+		case map[string]interface{}:
+			key = matchKey(n, key)
+			if last {
+				n[key] = value
+				return nil
+			}
+			next, ok := n[key]
+			if !ok {
+				next = map[string]interface{}{}
+				n[key] = next
+			}
+			node = next
+		default:
+			next, ok := child(node, key)
+			if !ok || last {
+				return fmt.Errorf("no table at %s", strings.Join(path[:i+1], "."))
+			}
+			node = next
+		}
+	}
+	return nil
+}
+
+func matchKey(table map[string]interface{}, key string) string {
+	for k := range table {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return strings.ToLower(key)
+}
+
+// merge src into dst, the tables are merged recursively, other values
+// including arrays are replaced.
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcTable, ok := v.(map[string]interface{})
+		if dstTable, ok2 := dst[k].(map[string]interface{}); ok && ok2 {
+			merge(dstTable, srcTable)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// parseValue parse the value of environment variable as a toml value, eg.
+// 10, true or ["a", "b"], otherwise it's taken as a string.
+func parseValue(s string) interface{} {
+	var v struct {
+		V interface{} `toml:"v"`
+	}
+	if _, err := toml.Decode("v = "+s, &v); err == nil && v.V != nil {
+		return v.V
+	}
+	return s
+}
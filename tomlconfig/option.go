@@ -0,0 +1,94 @@
+package tomlconfig
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	// EnvPrefix is the default prefix of the environment variables overriding config.
+	EnvPrefix = "ICAFE_"
+
+	envConfigFile = "ICAFE_CONFIG"
+	envName       = "ICAFE_ENV"
+
+	defaultConfigFile = "conf/config.toml"
+)
+
+type loader struct {
+	file      string
+	env       string
+	envPrefix string
+	environ   func() []string
+	strict    bool
+
+	watchInterval time.Duration
+}
+
+func defaultLoader() *loader {
+	l := &loader{
+		file:      defaultConfigFile,
+		env:       os.Getenv(envName),
+		envPrefix: EnvPrefix,
+		environ:   os.Environ,
+
+		watchInterval: 5 * time.Second,
+	}
+	if file := os.Getenv(envConfigFile); file != "" {
+		l.file = file
+	}
+	return l
+}
+
+type Option func(*loader)
+
+// File set the base config file.
+// Default: $ICAFE_CONFIG or conf/config.toml
+func File(path string) Option {
+	return func(l *loader) {
+		l.file = path
+	}
+}
+
+// Env set the environment, whose overlay file, eg. conf/config.prod.toml for
+// prod, is merged over the base file if exists.
+// Default: $ICAFE_ENV
+func Env(env string) Option {
+	return func(l *loader) {
+		l.env = env
+	}
+}
+
+// WithEnvPrefix set the prefix of the environment variables overriding
+// config, empty disable the overriding.
+// Default: ICAFE_
+func WithEnvPrefix(prefix string) Option {
+	return func(l *loader) {
+		l.envPrefix = prefix
+	}
+}
+
+// Environ set the source of the environment variables, mostly for testing.
+// Default: os.Environ
+func Environ(environ func() []string) Option {
+	return func(l *loader) {
+		l.environ = environ
+	}
+}
+
+// WatchInterval set the interval a Watcher checks the files for changes.
+// Default: 5s
+func WatchInterval(d time.Duration) Option {
+	return func(l *loader) {
+		l.watchInterval = d
+	}
+}
+
+// Strict report the keys not decoded into the struct as errors, eg. the
+// misspelled keys.
+// Default: false
+func Strict() Option {
+	return func(l *loader) {
+		l.strict = true
+	}
+}
@@ -0,0 +1,345 @@
+package tomlconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// Redacted replace the secrets in the dumped config.
+const Redacted = "******"
+
+// resolveTimeout limit the time resolving the secrets of a config.
+const resolveTimeout = 30 * time.Second
+
+// secretPattern match ${scheme:ref}, the reference escaped as $${scheme:ref}
+// is kept literally as ${scheme:ref}.
+var secretPattern = regexp.MustCompile(`\$?\$\{([a-zA-Z][a-zA-Z0-9_]*):([^}]*)\}`)
+
+// SecretProvider resolve the secret references of a scheme, eg. the
+// provider of "vault" resolves ${vault:secret/data/mysql#password}.
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretProviderFunc is a function as SecretProvider.
+type SecretProviderFunc func(ctx context.Context, ref string) (string, error)
+
+func (f SecretProviderFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]SecretProvider{}
+)
+
+func init() {
+	RegisterSecretProvider("env", SecretProviderFunc(resolveEnv), 0)
+	RegisterSecretProvider("file", SecretProviderFunc(resolveFile), time.Minute)
+	RegisterSecretProvider("vault", NewVaultProvider("", ""), 5*time.Minute)
+}
+
+// RegisterSecretProvider resolve the references of scheme by the provider,
+// the resolved secrets are cached for ttl, 0 disable the cache.
+func RegisterSecretProvider(scheme string, p SecretProvider, ttl time.Duration) {
+	if ttl > 0 {
+		p = NewCachedSecretProvider(p, ttl)
+	}
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[scheme] = p
+}
+
+func secretProvider(scheme string) (SecretProvider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[scheme]
+	return p, ok
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// CachedSecretProvider cache the secrets resolved by another provider.
+type CachedSecretProvider struct {
+	provider SecretProvider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	secrets map[string]cachedSecret
+}
+
+func NewCachedSecretProvider(p SecretProvider, ttl time.Duration) *CachedSecretProvider {
+	return &CachedSecretProvider{
+		provider: p,
+		ttl:      ttl,
+		secrets:  map[string]cachedSecret{},
+	}
+}
+
+func (p *CachedSecretProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	p.mu.Lock()
+	s, ok := p.secrets[ref]
+	p.mu.Unlock()
+	if ok && time.Now().Before(s.expires) {
+		return s.value, nil
+	}
+
+	value, err := p.provider.Resolve(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.secrets[ref] = cachedSecret{value: value, expires: time.Now().Add(p.ttl)}
+	p.mu.Unlock()
+	return value, nil
+}
+
+func resolveEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("env %s not set", name)
+	}
+	return value, nil
+}
+
+func resolveFile(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// VaultProvider resolve "path#key" from the KV secrets engine of vault, eg.
+// ${vault:secret/data/mysql#password}, both version 1 and 2 are supported.
+type VaultProvider struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// NewVaultProvider create a provider of the vault at addr, VAULT_ADDR and
+// VAULT_TOKEN are used if addr or token is empty.
+func NewVaultProvider(addr, token string) *VaultProvider {
+	return &VaultProvider{
+		addr:   addr,
+		token:  token,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (p *VaultProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	addr, token := p.addr, p.token
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if addr == "" {
+		return "", errors.New("vault address not set")
+	}
+
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || key == "" {
+		return "", fmt.Errorf("vault reference without key: %s", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "request vault")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault responded %s for %s", resp.Status, path)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.Wrap(err, "decode vault response")
+	}
+	data := body.Data
+	// the KV version 2 nests the secrets in data.data.
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// secrets remember the resolved secrets of a config for redaction.
+type secrets struct {
+	mu     sync.RWMutex
+	values map[string]struct{}
+}
+
+func newSecrets() *secrets {
+	return &secrets{values: map[string]struct{}{}}
+}
+
+func (s *secrets) add(value string) {
+	if s == nil || value == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[value] = struct{}{}
+}
+
+// redact replace the secret values in text, the longer ones first.
+func (s *secrets) redact(text string) string {
+	if s == nil {
+		return text
+	}
+	s.mu.RLock()
+	values := make([]string, 0, len(s.values))
+	for v := range s.values {
+		values = append(values, v)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	for _, v := range values {
+		text = strings.ReplaceAll(text, v, Redacted)
+	}
+	return text
+}
+
+// resolveSecrets replace the secret references in the string values, all
+// the unresolved references are reported at once.
+func (c *Config) resolveSecrets(ctx context.Context) error {
+	var errs ValidationErrors
+	resolveTree(ctx, c.tree, nil, c.secrets, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func resolveTree(ctx context.Context, node interface{}, key []string, s *secrets, errs *ValidationErrors) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			n[k] = resolveTree(ctx, v, append(append([]string(nil), key...), k), s, errs)
+		}
+	case []map[string]interface{}:
+		for i, v := range n {
+			resolveTree(ctx, v, append(append([]string(nil), key...), fmt.Sprint(i)), s, errs)
+		}
+	case []interface{}:
+		for i, v := range n {
+			n[i] = resolveTree(ctx, v, append(append([]string(nil), key...), fmt.Sprint(i)), s, errs)
+		}
+	case string:
+		return resolveString(ctx, n, strings.Join(key, "."), s, errs)
+	}
+	return node
+}
+
+func resolveString(ctx context.Context, value, key string, s *secrets, errs *ValidationErrors) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+
+	return secretPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		m := secretPattern.FindStringSubmatch(ref)
+		scheme, name := m[1], m[2]
+
+		p, ok := secretProvider(scheme)
+		if !ok {
+			*errs = append(*errs, &FieldError{Key: key, Message: fmt.Sprintf("unknown secret provider %q", scheme)})
+			return ref
+		}
+		secret, err := p.Resolve(ctx, name)
+		if err != nil {
+			*errs = append(*errs, &FieldError{Key: key, Message: fmt.Sprintf("resolve secret %s:%s error: %v", scheme, name, err)})
+			return ref
+		}
+		s.add(secret)
+		return secret
+	})
+}
+
+// Redact replace the resolved secrets in text, eg. an error message with a dsn.
+func (c *Config) Redact(text string) string {
+	if c == nil {
+		return text
+	}
+	return c.secrets.redact(text)
+}
+
+// Dump return the config in toml with the secrets redacted.
+func (c *Config) Dump() string {
+	if c == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(redactTree(c.tree, c.secrets)); err != nil {
+		return fmt.Sprintf("<encode config error: %v>", err)
+	}
+	return buf.String()
+}
+
+// Format print the config as Dump, so the secrets are never logged.
+func (c *Config) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, c.Dump())
+}
+
+// redactTree return a copy of node with the secrets redacted.
+func redactTree(node interface{}, s *secrets) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			m[k] = redactTree(v, s)
+		}
+		return m
+	case []map[string]interface{}:
+		tables := make([]map[string]interface{}, len(n))
+		for i, v := range n {
+			tables[i] = redactTree(v, s).(map[string]interface{})
+		}
+		return tables
+	case []interface{}:
+		values := make([]interface{}, len(n))
+		for i, v := range n {
+			values[i] = redactTree(v, s)
+		}
+		return values
+	case string:
+		return s.redact(n)
+	}
+	return node
+}
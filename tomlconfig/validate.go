@@ -0,0 +1,390 @@
+package tomlconfig
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FieldError is a problem of a config key.
+type FieldError struct {
+	Key     string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// ValidationErrors is all the problems found in a config.
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return fmt.Sprintf("%d config error(s): %s", len(e), strings.Join(msgs, "; "))
+}
+
+// ValidatorFunc check a value, param is the text after "=" in the rule.
+type ValidatorFunc func(v reflect.Value, param string) error
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[string]ValidatorFunc{
+		"url":      validateURL,
+		"hostport": validateHostPort,
+	}
+
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// RegisterValidator add a rule usable in the validate tags, eg. the sql
+// package registers "mysql_dsn".
+func RegisterValidator(name string, fn ValidatorFunc) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[name] = fn
+}
+
+// check fill the missing fields of v by the default tags, then validate them
+// by the validate tags, and the keys not decoded in strict mode. eg.
+//
+//	type Redis struct {
+//		Addr    string        `toml:"addr" validate:"required,url"`
+//		Timeout time.Duration `toml:"timeout" default:"200ms" validate:"min=10ms,max=5s"`
+//		Mode    string        `toml:"mode" default:"rw" validate:"oneof=rw ro"`
+//	}
+//
+// Except required, the rules are checked only if the value is set. tree is the
+// document decoded, telling whether a key is given even as a zero value.
+// element is whether the document is an element of an array of tables, its
+// "name" key selecting the element is not reported in strict mode.
+func check(v interface{}, md toml.MetaData, tree map[string]interface{}, prefix []string, strict, element bool) error {
+	var errs ValidationErrors
+	if strict {
+		for _, key := range md.Undecoded() {
+			if element && len(key) == 1 && key[0] == "name" {
+				continue
+			}
+			errs = append(errs, &FieldError{Key: joinKey(prefix, key), Message: "unknown key"})
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		checkStruct(rv, tree, prefix, nil, &errs)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkStruct walk the fields, node is the table decoded into rv and key is
+// its path relative to the decoded document.
+func checkStruct(rv reflect.Value, node interface{}, prefix, key []string, errs *ValidationErrors) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, tagged := tomlName(sf)
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if sf.Anonymous && !tagged && fv.Kind() == reflect.Struct {
+			checkStruct(fv, node, prefix, key, errs)
+			continue
+		}
+
+		fieldKey := append(append([]string(nil), key...), name)
+		addErr := func(msg string) {
+			*errs = append(*errs, &FieldError{Key: joinKey(prefix, fieldKey), Message: msg})
+		}
+
+		fieldNode, defined := tableKey(node, name)
+		if def, ok := sf.Tag.Lookup("default"); ok && fv.IsZero() && !defined {
+			if err := setString(fv, def); err != nil {
+				addErr(fmt.Sprintf("invalid default %q: %v", def, err))
+			}
+		}
+		if rules := sf.Tag.Get("validate"); rules != "" {
+			for _, msg := range validateValue(fv, rules, defined || !fv.IsZero()) {
+				addErr(msg)
+			}
+		}
+
+		checkNested(fv, fieldNode, prefix, fieldKey, errs)
+	}
+}
+
+// tableKey return the value of key in the table node, the key is matched
+// case-insensitively if not exactly as the decoder does.
+func tableKey(node interface{}, key string) (interface{}, bool) {
+	table, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if v, ok := table[key]; ok {
+		return v, true
+	}
+	for k, v := range table {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func checkNested(fv reflect.Value, node interface{}, prefix, key []string, errs *ValidationErrors) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return
+		}
+		fv = fv.Elem()
+	}
+
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type() != timeType {
+			checkStruct(fv, node, prefix, key, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			elem := fv.Index(i)
+			if elem.Kind() == reflect.Struct || elem.Kind() == reflect.Ptr {
+				index := strconv.Itoa(i)
+				elemNode, _ := child(node, index)
+				checkNested(elem, elemNode, prefix, append(append([]string(nil), key...), index), errs)
+			}
+		}
+	}
+}
+
+// validateValue return the messages of the rules failed, set is whether the
+// value is given in config or by default.
+func validateValue(v reflect.Value, rules string, set bool) []string {
+	var msgs []string
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "" {
+			continue
+		}
+		if name == "required" {
+			if v.IsZero() {
+				msgs = append(msgs, "is required")
+			}
+			continue
+		}
+		if !set {
+			continue
+		}
+
+		var err error
+		switch name {
+		case "min":
+			err = validateBound(v, param, true)
+		case "max":
+			err = validateBound(v, param, false)
+		case "oneof":
+			err = validateOneOf(v, param)
+		default:
+			validatorsMu.RLock()
+			fn, ok := validators[name]
+			validatorsMu.RUnlock()
+			if !ok {
+				err = fmt.Errorf("unknown rule %q", name)
+			} else {
+				err = fn(indirect(v), param)
+			}
+		}
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	return msgs
+}
+
+// validateBound check the min or max of numbers and durations, or of the
+// length of strings, slices and maps.
+func validateBound(v reflect.Value, param string, isMin bool) error {
+	v = indirect(v)
+	word := "less than"
+	if !isMin {
+		word = "greater than"
+	}
+
+	var value, bound float64
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(param)
+		if err != nil {
+			return fmt.Errorf("invalid rule param %q", param)
+		}
+		if isMin && v.Int() < int64(d) || !isMin && v.Int() > int64(d) {
+			return fmt.Errorf("must not be %s %s", word, param)
+		}
+		return nil
+	case v.Kind() == reflect.String || v.Kind() == reflect.Slice || v.Kind() == reflect.Map || v.Kind() == reflect.Array:
+		value = float64(v.Len())
+		word = "shorter than"
+		if !isMin {
+			word = "longer than"
+		}
+	case v.CanInt():
+		value = float64(v.Int())
+	case v.CanUint():
+		value = float64(v.Uint())
+	case v.CanFloat():
+		value = v.Float()
+	default:
+		return fmt.Errorf("rule min/max unsupported for %s", v.Type())
+	}
+
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("invalid rule param %q", param)
+	}
+	if isMin && value < bound || !isMin && value > bound {
+		return fmt.Errorf("must not be %s %s", word, param)
+	}
+	return nil
+}
+
+func validateOneOf(v reflect.Value, param string) error {
+	s := fmt.Sprint(indirect(v).Interface())
+	options := strings.Fields(param)
+	for _, o := range options {
+		if s == o {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of [%s], got %q", strings.Join(options, " "), s)
+}
+
+func validateURL(v reflect.Value, _ string) error {
+	return EachString(v, func(s string) error {
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid url %q", s)
+		}
+		return nil
+	})
+}
+
+func validateHostPort(v reflect.Value, _ string) error {
+	return EachString(v, func(s string) error {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			return fmt.Errorf("invalid host:port %q", s)
+		}
+		return nil
+	})
+}
+
+// EachString apply fn to a string or each string of a slice, it's handy to
+// write a ValidatorFunc.
+func EachString(v reflect.Value, fn func(string) error) error {
+	switch {
+	case v.Kind() == reflect.String:
+		return fn(v.String())
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() == reflect.String:
+		for i := 0; i < v.Len(); i++ {
+			if err := fn(v.Index(i).String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("rule unsupported for %s", v.Type())
+}
+
+// setString set the value parsed from s, a slice is separated by comma.
+func setString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.CanInt():
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.CanUint():
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case v.CanFloat():
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice:
+		parts := strings.Split(s, ",")
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setString(slice.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("default unsupported for %s", v.Type())
+	}
+	return nil
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// tomlName return the key of the field, and whether it's named by a toml tag.
+func tomlName(sf reflect.StructField) (string, bool) {
+	tag := sf.Tag.Get("toml")
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return sf.Name, false
+}
+
+func joinKey(prefix []string, key toml.Key) string {
+	return strings.Join(append(append([]string(nil), prefix...), key...), ".")
+}
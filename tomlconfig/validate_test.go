@@ -0,0 +1,114 @@
+package tomlconfig
+
+import (
+	"testing"
+	"time"
+)
+
+type testServer struct {
+	Name    string        `toml:"name"`
+	Timeout time.Duration `toml:"timeout" default:"1s"`
+	Enabled bool          `toml:"enabled" default:"true"`
+}
+
+type testUntagged struct {
+	Timeout time.Duration `default:"1s"`
+	Enabled bool          `default:"true"`
+	Retries int           `default:"3"`
+}
+
+type testDefaults struct {
+	Untagged testUntagged  `toml:"untagged"`
+	Servers  []testServer  `toml:"servers"`
+	Pointers []*testServer `toml:"pointers"`
+}
+
+func TestDecodeDefaultsKeepZeroValues(t *testing.T) {
+	c, err := Parse(`
+[untagged]
+timeout = "0s"
+Enabled = false
+
+[[servers]]
+name = "zero"
+timeout = "0s"
+enabled = false
+
+[[servers]]
+name = "missing"
+
+[[pointers]]
+name = "zero"
+Timeout = "0s"
+ENABLED = false
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v testDefaults
+	if err := c.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key     string
+		timeout time.Duration
+		enabled bool
+	}{
+		{"untagged", 0, false},
+		{"servers.0", 0, false},
+		{"servers.1", time.Second, true},
+		{"pointers.0", 0, false},
+	}
+	got := map[string]testServer{
+		"untagged":   {Timeout: v.Untagged.Timeout, Enabled: v.Untagged.Enabled},
+		"servers.0":  v.Servers[0],
+		"servers.1":  v.Servers[1],
+		"pointers.0": *v.Pointers[0],
+	}
+	for _, tt := range tests {
+		if s := got[tt.key]; s.Timeout != tt.timeout || s.Enabled != tt.enabled {
+			t.Errorf("%s = {timeout: %s, enabled: %t}, want {timeout: %s, enabled: %t}",
+				tt.key, s.Timeout, s.Enabled, tt.timeout, tt.enabled)
+		}
+	}
+	// the missing untagged key still gets the default.
+	if v.Untagged.Retries != 3 {
+		t.Errorf("untagged.Retries = %d, want 3", v.Untagged.Retries)
+	}
+}
+
+func TestDecodeKeyElementDefaults(t *testing.T) {
+	c, err := Parse(`
+[[servers]]
+name = "a"
+timeout = "0s"
+enabled = false
+
+[[servers]]
+name = "b"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key     string
+		timeout time.Duration
+		enabled bool
+	}{
+		{"servers.a", 0, false},
+		{"servers.0", 0, false},
+		{"servers.b", time.Second, true},
+	}
+	for _, tt := range tests {
+		var s testServer
+		if err := c.DecodeKey(tt.key, &s); err != nil {
+			t.Fatalf("DecodeKey(%s) error = %v", tt.key, err)
+		}
+		if s.Timeout != tt.timeout || s.Enabled != tt.enabled {
+			t.Errorf("DecodeKey(%s) = {timeout: %s, enabled: %t}, want {timeout: %s, enabled: %t}",
+				tt.key, s.Timeout, s.Enabled, tt.timeout, tt.enabled)
+		}
+	}
+}
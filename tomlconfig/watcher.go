@@ -0,0 +1,278 @@
+package tomlconfig
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+// Watcher reload the config once the files changed or SIGHUP received.
+//
+// The new config is validated by the validators and the subscribers before
+// it's swapped in, a config failed to parse or validate is dropped and the
+// last good one is kept.
+type Watcher struct {
+	loader  *loader
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	modTimes    map[string]time.Time
+	validators  []func(*Config) error
+	subscribers []subscriber
+	onError     func(error)
+
+	// notifyMu guard the notifications pending in the order of the reloads,
+	// they are run out of mu so a subscriber may reload or subscribe.
+	notifyMu  sync.Mutex
+	pending   []func()
+	notifying bool
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// subscriber is notified after a new config swapped in, prepare is called
+// before that to validate the new config and returns the notification.
+type subscriber struct {
+	prepare func(old, new *Config) (notify func(), err error)
+}
+
+// NewWatcher load the config by the options, call Start to watch the changes.
+func NewWatcher(opts ...Option) (*Watcher, error) {
+	l := defaultLoader()
+	for _, o := range opts {
+		o(l)
+	}
+
+	w := &Watcher{
+		loader: l,
+		onError: func(err error) {
+			log.Errorf("Reload config error: %v", err)
+		},
+		stop: make(chan struct{}),
+	}
+	c, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	w.current.Store(c)
+	w.modTimes = w.statFiles()
+	return w, nil
+}
+
+// Config return the current config.
+func (w *Watcher) Config() *Config {
+	return w.current.Load()
+}
+
+// Validate add a validator of the new configs.
+func (w *Watcher) Validate(fn func(*Config) error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.validators = append(w.validators, fn)
+}
+
+// OnError set the function called with the error of a failed reload, the
+// error is logged by default.
+func (w *Watcher) OnError(fn func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onError = fn
+}
+
+// OnChange call fn with the old and the new config after every reload.
+func (w *Watcher) OnChange(fn func(old, new *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, subscriber{
+		prepare: func(old, new *Config) (func(), error) {
+			return func() { fn(old, new) }, nil
+		},
+	})
+}
+
+// Subscribe decode the section of key into T on every reload, and call fn
+// with the old and the new value if changed. A section failed to decode
+// rejects the new config. eg.
+//
+//	tomlconfig.Subscribe(w, "ratelimit", func(old, new RateLimit) {
+//		limiter.SetLimit(new.QPS)
+//	})
+func Subscribe[T any](w *Watcher, key string, fn func(old, new T)) error {
+	var initial T
+	if err := w.Config().DecodeKey(key, &initial); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, subscriber{
+		prepare: func(oldConf, newConf *Config) (func(), error) {
+			var oldValue, newValue T
+			if err := oldConf.DecodeKey(key, &oldValue); err != nil {
+				return nil, err
+			}
+			if err := newConf.DecodeKey(key, &newValue); err != nil {
+				return nil, errors.Wrapf(err, "decode %s", key)
+			}
+			if reflect.DeepEqual(oldValue, newValue) {
+				return nil, nil
+			}
+			return func() { fn(oldValue, newValue) }, nil
+		},
+	})
+	return nil
+}
+
+// Start watching the changes in background.
+func (w *Watcher) Start() {
+	go w.watch()
+}
+
+// Stop watching the changes.
+func (w *Watcher) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+}
+
+// Reload load the config, and swap it in if it's valid. The subscribers are
+// notified before it returns, unless called by a subscriber, then they are
+// notified after the running subscriber.
+func (w *Watcher) Reload() error {
+	w.mu.Lock()
+	w.modTimes = w.statFiles()
+	err := w.reload()
+	onError := w.onError
+	w.mu.Unlock()
+
+	if err != nil {
+		onError(err)
+	}
+	w.notify()
+	return err
+}
+
+// reload must be locked, the notifications of the subscribers are queued for
+// notify.
+func (w *Watcher) reload() error {
+	newConf, err := w.loader.load()
+	if err != nil {
+		return errors.Wrap(err, "reload config")
+	}
+	for _, validate := range w.validators {
+		if err := validate(newConf); err != nil {
+			return errors.Wrap(err, "validate config")
+		}
+	}
+
+	oldConf := w.Config()
+	notifications := make([]func(), 0, len(w.subscribers))
+	for _, s := range w.subscribers {
+		notify, err := s.prepare(oldConf, newConf)
+		if err != nil {
+			return errors.Wrap(err, "validate config")
+		}
+		if notify != nil {
+			notifications = append(notifications, notify)
+		}
+	}
+
+	w.current.Store(newConf)
+	w.notifyMu.Lock()
+	w.pending = append(w.pending, notifications...)
+	w.notifyMu.Unlock()
+	return nil
+}
+
+// notify run the pending notifications in order, one at a time. A call made
+// while they are running returns at once, leaving its notifications to the
+// running one.
+func (w *Watcher) notify() {
+	w.notifyMu.Lock()
+	defer w.notifyMu.Unlock()
+	if w.notifying {
+		return
+	}
+	w.notifying = true
+	for len(w.pending) > 0 {
+		notify := w.pending[0]
+		w.pending = w.pending[1:]
+		w.notifyMu.Unlock()
+		safeNotify(notify)
+		w.notifyMu.Lock()
+	}
+	w.notifying = false
+}
+
+func (w *Watcher) watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(w.loader.watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-hup:
+			_ = w.Reload()
+		case <-ticker.C:
+			w.mu.Lock()
+			modTimes := w.statFiles()
+			changed := !reflect.DeepEqual(modTimes, w.modTimes)
+			var err error
+			if changed {
+				w.modTimes = modTimes
+				err = w.reload()
+			}
+			onError := w.onError
+			w.mu.Unlock()
+
+			if err != nil {
+				onError(err)
+			}
+			if changed {
+				w.notify()
+			}
+		}
+	}
+}
+
+// statFiles return the modification time of the base and the overlay file,
+// zero if not exist.
+func (w *Watcher) statFiles() map[string]time.Time {
+	modTimes := map[string]time.Time{}
+	for _, path := range []string{w.loader.file, w.loader.overlayFile()} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		} else {
+			modTimes[path] = time.Time{}
+		}
+	}
+	return modTimes
+}
+
+// safeNotify keep a panicking subscriber from breaking the watcher.
+func safeNotify(notify func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Config subscriber panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	notify()
+}
@@ -0,0 +1,177 @@
+package tomlconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testLimit struct {
+	QPS int `toml:"qps"`
+}
+
+// newTestWatcher write data to a config file watched by a new watcher.
+func newTestWatcher(t *testing.T, data string) (*Watcher, func(string)) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(data)
+
+	w, err := NewWatcher(File(path), WithEnvPrefix(""), WatchInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Stop)
+	return w, write
+}
+
+func TestWatcherReload(t *testing.T) {
+	w, write := newTestWatcher(t, "name = \"a\"\n[limit]\nqps = 1\n")
+
+	var changes []string
+	w.OnChange(func(old, new *Config) {
+		changes = append(changes, old.String("name")+"->"+new.String("name"))
+	})
+	var limits []testLimit
+	if err := Subscribe(w, "limit", func(old, new testLimit) {
+		limits = append(limits, old, new)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	write("name = \"b\"\n[limit]\nqps = 2\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Config().String("name"); got != "b" {
+		t.Errorf("name = %q, want b", got)
+	}
+	if len(changes) != 1 || changes[0] != "a->b" {
+		t.Errorf("OnChange got %v, want [a->b]", changes)
+	}
+	if len(limits) != 2 || limits[0].QPS != 1 || limits[1].QPS != 2 {
+		t.Errorf("Subscribe got %v, want [{1} {2}]", limits)
+	}
+
+	// the subscriber of an unchanged section is not notified.
+	write("name = \"c\"\n[limit]\nqps = 2\n")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(limits) != 2 {
+		t.Errorf("Subscribe of unchanged section got %v", limits)
+	}
+}
+
+func TestWatcherRejectInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		validate func(*Config) error
+	}{
+		{name: "parse", data: "name = \n"},
+		{name: "subscriber decode", data: "name = \"b\"\n[limit]\nqps = \"x\"\n"},
+		{name: "validator", data: "name = \"b\"\n[limit]\nqps = 2\n", validate: func(c *Config) error {
+			if c.String("name") == "b" {
+				return errors.New("name b not allowed")
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		w, write := newTestWatcher(t, "name = \"a\"\n[limit]\nqps = 1\n")
+		if tt.validate != nil {
+			w.Validate(tt.validate)
+		}
+		notified := false
+		if err := Subscribe(w, "limit", func(old, new testLimit) { notified = true }); err != nil {
+			t.Fatal(err)
+		}
+		var onError error
+		w.OnError(func(err error) { onError = err })
+
+		old := w.Config()
+		write(tt.data)
+		err := w.Reload()
+		if err == nil {
+			t.Errorf("%s: Reload error = nil", tt.name)
+		}
+		if onError != err {
+			t.Errorf("%s: OnError got %v, want %v", tt.name, onError, err)
+		}
+		if w.Config() != old {
+			t.Errorf("%s: the config is swapped by an invalid one", tt.name)
+		}
+		if notified {
+			t.Errorf("%s: subscriber notified of an invalid config", tt.name)
+		}
+	}
+}
+
+func TestWatcherSubscriberReentrant(t *testing.T) {
+	w, write := newTestWatcher(t, "name = \"a\"\n[limit]\nqps = 1\n")
+
+	var names []string
+	w.OnChange(func(old, new *Config) {
+		names = append(names, new.String("name"))
+		if new.String("name") != "b" {
+			return
+		}
+		// reload and subscribe from a subscriber.
+		write("name = \"c\"\n[limit]\nqps = 1\n")
+		if err := w.Reload(); err != nil {
+			t.Error(err)
+		}
+		if err := Subscribe(w, "limit", func(old, new testLimit) {}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		write("name = \"b\"\n[limit]\nqps = 1\n")
+		if err := w.Reload(); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload from a subscriber deadlocked")
+	}
+	// the nested reload is notified after the running subscriber.
+	if len(names) != 2 || names[0] != "b" || names[1] != "c" {
+		t.Errorf("notified %v, want [b c]", names)
+	}
+}
+
+func TestWatcherStart(t *testing.T) {
+	w, write := newTestWatcher(t, "name = \"a\"\n")
+	changed := make(chan string, 1)
+	w.OnChange(func(old, new *Config) {
+		changed <- new.String("name")
+	})
+	w.Start()
+
+	write("name = \"b\"\n")
+	// the modification time may not change within the resolution of the file system.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(w.loader.file, future, future); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-changed:
+		if name != "b" {
+			t.Errorf("name = %q, want b", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change of the file is not reloaded")
+	}
+}
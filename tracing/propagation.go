@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	b3Header          = "b3"
+	b3TraceIDHeader   = "X-B3-TraceId"
+	b3SpanIDHeader    = "X-B3-SpanId"
+	b3SampledHeader   = "X-B3-Sampled"
+	b3FlagsHeader     = "X-B3-Flags"
+)
+
+// Carrier is where the span context is propagated in, eg. http headers or gRPC metadata.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// HeaderCarrier adapt http.Header to Carrier.
+type HeaderCarrier http.Header
+
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// Extract read the span context from W3C traceparent or B3 headers, in this order.
+func Extract(carrier Carrier) (SpanContext, bool) {
+	if sc, ok := extractTraceparent(carrier.Get(traceparentHeader)); ok {
+		return sc, true
+	}
+	if sc, ok := extractB3Single(carrier.Get(b3Header)); ok {
+		return sc, true
+	}
+	return extractB3(carrier)
+}
+
+// Inject write the span context as both W3C traceparent and B3 headers.
+func Inject(sc SpanContext, carrier Carrier) {
+	if !sc.Valid() {
+		return
+	}
+
+	flags, sampled := "00", "0"
+	if sc.Sampled {
+		flags, sampled = "01", "1"
+	}
+	carrier.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags))
+	carrier.Set(b3TraceIDHeader, sc.TraceID)
+	carrier.Set(b3SpanIDHeader, sc.SpanID)
+	carrier.Set(b3SampledHeader, sampled)
+}
+
+// extractTraceparent parse "version-traceid-spanid-flags".
+func extractTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+
+	sc := SpanContext{
+		TraceID: strings.ToLower(parts[1]),
+		SpanID:  strings.ToLower(parts[2]),
+		Sampled: isHex(parts[3]) && hexValue(parts[3][1])&1 == 1,
+	}
+	if !sc.Valid() || !isHex(sc.TraceID) || !isHex(sc.SpanID) || isZero(sc.TraceID) || isZero(sc.SpanID) {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// extractB3Single parse "traceid-spanid-sampled-parentspanid", the last two are optional.
+func extractB3Single(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 2 {
+		return SpanContext{}, false
+	}
+
+	sampled := ""
+	if len(parts) > 2 {
+		sampled = parts[2]
+	}
+	return newB3SpanContext(parts[0], parts[1], sampled, "")
+}
+
+func extractB3(carrier Carrier) (SpanContext, bool) {
+	return newB3SpanContext(
+		carrier.Get(b3TraceIDHeader),
+		carrier.Get(b3SpanIDHeader),
+		carrier.Get(b3SampledHeader),
+		carrier.Get(b3FlagsHeader),
+	)
+}
+
+func newB3SpanContext(traceID, spanID, sampled, flags string) (SpanContext, bool) {
+	traceID = strings.ToLower(strings.TrimSpace(traceID))
+	spanID = strings.ToLower(strings.TrimSpace(spanID))
+	if len(traceID) == 16 {
+		// 64 bits trace id
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+
+	sc := SpanContext{TraceID: traceID, SpanID: spanID}
+	if !sc.Valid() || !isHex(traceID) || !isHex(spanID) {
+		return SpanContext{}, false
+	}
+
+	switch {
+	case flags == "1", sampled == "1", sampled == "d", strings.EqualFold(sampled, "true"):
+		sc.Sampled = true
+	case sampled == "":
+		// the decision is deferred to us
+		sc.Sampled = defaultTracer.sample()
+	}
+	return sc, true
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if hexValue(s[i]) < 0 {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func hexValue(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
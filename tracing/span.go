@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/YLeseclaireurs/icafe/log"
+)
+
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindServer
+	KindClient
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	default:
+		return "internal"
+	}
+}
+
+// SpanContext is the part of a span propagated across processes.
+type SpanContext struct {
+	TraceID string // 32 hex characters
+	SpanID  string // 16 hex characters
+	Sampled bool
+}
+
+func (sc SpanContext) Valid() bool {
+	return len(sc.TraceID) == 32 && len(sc.SpanID) == 16
+}
+
+// Span is a timed operation of a trace.
+type Span struct {
+	SpanContext
+
+	ParentSpanID string
+	Name         string
+	Kind         Kind
+	Start        time.Time
+	End          time.Time
+	Err          error
+
+	mu         sync.Mutex
+	attributes map[string]string
+	ended      bool
+}
+
+type spanContextKey struct{}
+
+// StartSpan start a span as the child of the span in ctx, or a new trace if
+// there is none, the returned context carries the span.
+func StartSpan(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	parent, _ := SpanContextFromContext(ctx)
+	return StartSpanWithParent(ctx, parent, name, kind)
+}
+
+// StartSpanWithParent start a span as the child of a remote parent, eg.
+// extracted from the request headers. A new trace is started if parent is
+// invalid.
+func StartSpanWithParent(ctx context.Context, parent SpanContext, name string, kind Kind) (context.Context, *Span) {
+	span := &Span{
+		Name:  name,
+		Kind:  kind,
+		Start: time.Now(),
+	}
+	if parent.Valid() {
+		span.TraceID = parent.TraceID
+		span.ParentSpanID = parent.SpanID
+		span.Sampled = parent.Sampled
+	} else {
+		span.TraceID = newID(16)
+		span.Sampled = defaultTracer.sample()
+	}
+	span.SpanID = newID(8)
+
+	ctx = context.WithValue(ctx, spanContextKey{}, span)
+	ctx = log.ContextWithTrace(ctx, span.TraceID, span.SpanID)
+	return ctx, span
+}
+
+// SpanFromContext return the span started by StartSpan, nil if none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext return the context of the span in ctx.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext, true
+	}
+	return SpanContext{}, false
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+func (s *Span) Attributes() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attributes := make(map[string]string, len(s.attributes))
+	for k, v := range s.attributes {
+		attributes[k] = v
+	}
+	return attributes
+}
+
+// RecordError mark the span failed without finishing it.
+func (s *Span) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Err = err
+}
+
+// Finish end the span with the error of the operation, and export it if sampled.
+// A nil err keeps the error recorded before. Only the first call takes effect.
+func (s *Span) Finish(err error) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	if err != nil {
+		s.Err = err
+	}
+	s.mu.Unlock()
+
+	if s.Sampled {
+		defaultTracer.export(s)
+	}
+}
+
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
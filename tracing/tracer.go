@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Exporter receive the finished sampled spans, it must be safe for concurrent use.
+type Exporter interface {
+	Export(span *Span)
+}
+
+type tracer struct {
+	mu         sync.RWMutex
+	exporters  []Exporter
+	sampleRate float64
+}
+
+var defaultTracer = &tracer{sampleRate: 1}
+
+// AddExporter register an exporter for the finished spans.
+func AddExporter(e Exporter) {
+	defaultTracer.mu.Lock()
+	defer defaultTracer.mu.Unlock()
+	defaultTracer.exporters = append(defaultTracer.exporters, e)
+}
+
+// SetSampleRate set the rate of new traces to be sampled, the traces
+// continued from remote follow the decision of the remote.
+// Default: 1
+func SetSampleRate(rate float64) {
+	defaultTracer.mu.Lock()
+	defer defaultTracer.mu.Unlock()
+	defaultTracer.sampleRate = rate
+}
+
+func (t *tracer) sample() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.sampleRate >= 1 || rand.Float64() < t.sampleRate
+}
+
+func (t *tracer) export(span *Span) {
+	t.mu.RLock()
+	exporters := t.exporters
+	t.mu.RUnlock()
+
+	for _, e := range exporters {
+		e.Export(span)
+	}
+}
+
+// MemoryExporter keep the exported spans in memory, mostly for testing.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (e *MemoryExporter) Export(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans return the exported spans in order.
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
@@ -3,12 +3,24 @@ package utils
 import (
 	"fmt"
 	"runtime/debug"
+	"sync/atomic"
 )
 
+var panicHandler atomic.Value
+
+// SetPanicHandler set the function called with the panics recovered by
+// SafelyRun, eg. to report them. It's called before the stack unwound.
+func SetPanicHandler(handler func(recovered interface{})) {
+	panicHandler.Store(handler)
+}
+
 func SafelyRun(function func()) (err error) {
 	// 返回是否成功执行
 	defer func() {
 		if r := recover(); r != nil {
+			if handler, ok := panicHandler.Load().(func(interface{})); ok && handler != nil {
+				handler(r)
+			}
 			if e, ok := r.(error); ok {
 				err = fmt.Errorf("%w\n%s", e, string(debug.Stack()))
 			} else {
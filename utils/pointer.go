@@ -1,6 +1,9 @@
 package utils
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 func DerefCtx(a, b context.Context) context.Context {
 	if a != nil {
@@ -29,3 +32,10 @@ func DerefInt(a *int, b int) int {
 	}
 	return b
 }
+
+func DerefDuration(a *time.Duration, b time.Duration) time.Duration {
+	if a != nil {
+		return *a
+	}
+	return b
+}